@@ -0,0 +1,134 @@
+package analyser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Values for Config.CommitMethod
+const (
+	// Commit with git and push the branch with the token in the remote URL
+	CommitWithGit string = "git"
+	// Create the commit through the provider's API, GitHub marks it as verified
+	CommitWithAPI string = "api"
+)
+
+// Git file modes as the GitHub API expects them
+const (
+	fileMode       string = "100644"
+	executableMode string = "100755"
+	symlinkMode    string = "120000"
+)
+
+// Providers that can commit through their API rather than git push
+type apiCommitter interface {
+	// Commits changes on top of repo.LatestCommit and points branch at the
+	// commit, replacing whatever the branch pointed at before
+	CommitChanges(ctx context.Context, repo Repository, branch, message string, changes []fileChange) error
+}
+
+// A file changed in the working tree
+type fileChange struct {
+	// Relative to the root of the repository, always with forward slashes
+	Path    string
+	Mode    string
+	Content []byte
+	Deleted bool
+}
+
+// Reads the staged changes in workDir and commits them to branch through the provider's API
+func commitThroughAPI(ctx context.Context, provider Provider, repo Repository, workDir, branch, message string) error {
+	committer, ok := provider.(apiCommitter)
+	if !ok {
+		return fmt.Errorf("commit method %s is only supported on GitHub", CommitWithAPI)
+	}
+
+	changedRunner := buildChangedFilesCommand()
+	changedRunner.Dir = workDir
+	changes, err := stagedChanges(changedRunner, workDir)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return errors.New("nothing staged to commit")
+	}
+
+	return committer.CommitChanges(ctx, repo, branch, message, changes)
+}
+
+// Lists the paths of the staged changes, NUL separated so unusual file names survive
+func buildChangedFilesCommand() *exec.Cmd {
+	return exec.Command("git", "diff", "--cached", "--name-only", "--no-renames", "-z")
+}
+
+// Returns the paths listed by the changed files command
+func changedPaths(exec CommandRunner) ([]string, error) {
+	output, err := exec.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, path := range strings.Split(string(output), "\x00") {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths, nil
+}
+
+// Reads every file listed by the changed files command from workDir
+func stagedChanges(exec CommandRunner, workDir string) ([]fileChange, error) {
+	paths, err := changedPaths(exec)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []fileChange
+	for _, path := range paths {
+		change, err := readChange(workDir, path)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+func readChange(workDir, path string) (fileChange, error) {
+	change := fileChange{Path: path, Mode: fileMode}
+	fullPath := filepath.Join(workDir, filepath.FromSlash(path))
+
+	info, err := os.Lstat(fullPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		change.Deleted = true
+		return change, nil
+	}
+	if err != nil {
+		return change, err
+	}
+
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		target, err := os.Readlink(fullPath)
+		if err != nil {
+			return change, err
+		}
+		change.Mode = symlinkMode
+		change.Content = []byte(target)
+		return change, nil
+	case info.Mode()&0111 != 0:
+		change.Mode = executableMode
+	}
+
+	change.Content, err = os.ReadFile(fullPath)
+	return change, err
+}
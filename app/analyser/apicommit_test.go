@@ -0,0 +1,60 @@
+package analyser
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type MockChangedFilesCommand struct {
+	output string
+}
+
+func (m *MockChangedFilesCommand) Run() error {
+	return nil
+}
+
+func (m *MockChangedFilesCommand) Output() ([]byte, error) {
+	return []byte(m.output), nil
+}
+
+func TestBuildChangedFilesCommand(t *testing.T) {
+	cmd := buildChangedFilesCommand()
+
+	expectedArgs := []string{"git", "diff", "--cached", "--name-only", "--no-renames", "-z"}
+	assert.Equal(t, expectedArgs, cmd.Args)
+}
+
+func TestStagedChanges(t *testing.T) {
+	workDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(workDir, "recipes"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(workDir, "recipes", "default.rb"), []byte("package 'snort'\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(workDir, "bin script.sh"), []byte("#!/bin/sh\n"), 0755))
+	assert.NoError(t, os.Symlink("recipes/default.rb", filepath.Join(workDir, "link.rb")))
+
+	runner := &MockChangedFilesCommand{output: "recipes/default.rb\x00bin script.sh\x00link.rb\x00attributes/old.rb\x00"}
+
+	changes, err := stagedChanges(runner, workDir)
+	assert.NoError(t, err)
+
+	expected := []fileChange{
+		{Path: "recipes/default.rb", Mode: "100644", Content: []byte("package 'snort'\n")},
+		{Path: "bin script.sh", Mode: "100755", Content: []byte("#!/bin/sh\n")},
+		{Path: "link.rb", Mode: "120000", Content: []byte("recipes/default.rb")},
+		{Path: "attributes/old.rb", Mode: "100644", Deleted: true},
+	}
+	assert.Equal(t, expected, changes)
+}
+
+func TestCommitThroughAPI(t *testing.T) {
+	t.Run("Only GitHub can commit through the API", func(t *testing.T) {
+		gitlab := newGitlabProvider(&http.Client{}, GitlabServer{Token: "glToken"})
+
+		err := commitThroughAPI(context.Background(), gitlab, NewRepo("stylelia", "snort", "main"), t.TempDir(), "stylelia/cookstyle_7.25.0", "message")
+		assert.EqualError(t, err, "commit method api is only supported on GitHub")
+	})
+}
@@ -0,0 +1,93 @@
+package analyser
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Values for Config.AutoMerge, as GitHub names them in lower case
+const (
+	MergeWithMerge  string = "merge"
+	MergeWithSquash string = "squash"
+	MergeWithRebase string = "rebase"
+)
+
+const enableAutoMergeMutation string = `mutation($pullRequestId: ID!, $mergeMethod: PullRequestMergeMethod!) {
+  enablePullRequestAutoMerge(input: {pullRequestId: $pullRequestId, mergeMethod: $mergeMethod}) {
+    pullRequest {
+      number
+    }
+  }
+}`
+
+// Providers that can merge a change request by themselves once its checks pass
+type autoMerger interface {
+	EnableAutoMerge(ctx context.Context, repo Repository, change *ChangeRequest, method string) error
+}
+
+// Turns on auto-merge for change with method, one of the MergeWith values
+func enableAutoMerge(ctx context.Context, provider Provider, repo Repository, change *ChangeRequest, method string) error {
+	merger, ok := provider.(autoMerger)
+	if !ok {
+		return fmt.Errorf("auto-merge is only supported on GitHub")
+	}
+
+	return merger.EnableAutoMerge(ctx, repo, change, method)
+}
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type graphQLResponse struct {
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// The REST API doesn't have auto-merge, so it goes through GraphQL with the
+// Pull Request's node id
+func (g *githubProvider) EnableAutoMerge(ctx context.Context, repo Repository, change *ChangeRequest, method string) error {
+	pr, _, err := g.client.PullRequests.Get(ctx, repo.Org, repo.Name, change.Number)
+	if err != nil {
+		return githubError(err)
+	}
+
+	body := graphQLRequest{
+		Query: enableAutoMergeMutation,
+		Variables: map[string]interface{}{
+			"pullRequestId": pr.GetNodeID(),
+			"mergeMethod":   strings.ToUpper(method),
+		},
+	}
+	request, err := g.client.NewRequest("POST", g.graphQLURL(), body)
+	if err != nil {
+		return err
+	}
+
+	var response graphQLResponse
+	_, err = g.client.Do(ctx, request, &response)
+	if err != nil {
+		return githubError(err)
+	}
+
+	// GraphQL answers 200 with the errors in the body
+	if len(response.Errors) > 0 {
+		return fmt.Errorf("github: enablePullRequestAutoMerge: %s", response.Errors[0].Message)
+	}
+
+	return nil
+}
+
+// github.com serves GraphQL at /graphql, Enterprise Server at /api/graphql
+// next to /api/v3
+func (g *githubProvider) graphQLURL() string {
+	base := g.client.BaseURL.String()
+	if strings.HasSuffix(base, "/api/v3/") {
+		return strings.TrimSuffix(base, "v3/") + "graphql"
+	}
+
+	return base + "graphql"
+}
@@ -0,0 +1,89 @@
+package analyser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v39/github"
+	"github.com/stretchr/testify/assert"
+)
+
+// Fakes the Pull Request lookup and a GraphQL endpoint that answers the
+// auto-merge mutation with errors when given
+func newFakeGraphQL(t *testing.T, errors string, received *graphQLRequest) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/stylelia/snort/pulls/4", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"number": 4, "node_id": "PR_kwDOAbc123"}`)
+	})
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(received))
+		if errors != "" {
+			fmt.Fprintf(w, `{"data": {"enablePullRequestAutoMerge": null}, "errors": %s}`, errors)
+			return
+		}
+		fmt.Fprint(w, `{"data": {"enablePullRequestAutoMerge": {"pullRequest": {"number": 4}}}}`)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestEnableAutoMerge(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRepo("stylelia", "snort", "main")
+	change := &ChangeRequest{Number: 4, URL: "https://github.com/stylelia/snort/pull/4"}
+
+	t.Run("Sends the mutation with the Pull Request's node id and the merge method", func(t *testing.T) {
+		var received graphQLRequest
+		server := newFakeGraphQL(t, "", &received)
+		defer server.Close()
+		provider := &githubProvider{client: newTestGithubClient(server)}
+
+		err := enableAutoMerge(ctx, provider, repo, change, MergeWithSquash)
+		assert.NoError(t, err)
+		assert.True(t, strings.Contains(received.Query, "enablePullRequestAutoMerge"))
+		assert.Equal(t, map[string]interface{}{"pullRequestId": "PR_kwDOAbc123", "mergeMethod": "SQUASH"}, received.Variables)
+	})
+
+	t.Run("Returns the GraphQL errors", func(t *testing.T) {
+		var received graphQLRequest
+		server := newFakeGraphQL(t, `[{"type": "UNPROCESSABLE", "message": "Pull request Auto merge is not allowed for this repository"}]`, &received)
+		defer server.Close()
+		provider := &githubProvider{client: newTestGithubClient(server)}
+
+		err := enableAutoMerge(ctx, provider, repo, change, MergeWithMerge)
+		assert.EqualError(t, err, "github: enablePullRequestAutoMerge: Pull request Auto merge is not allowed for this repository")
+	})
+
+	t.Run("Is only supported on GitHub", func(t *testing.T) {
+		gitea := newGiteaProvider(&http.Client{}, GiteaServer{URL: "https://gitea.example.com", Token: "gtToken"})
+
+		err := enableAutoMerge(ctx, gitea, repo, change, MergeWithMerge)
+		assert.EqualError(t, err, "auto-merge is only supported on GitHub")
+	})
+}
+
+func TestGraphQLURL(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		baseURL  string
+		expected string
+	}{
+		{desc: "github.com", baseURL: "https://api.github.com/", expected: "https://api.github.com/graphql"},
+		{desc: "Enterprise Server", baseURL: "https://github.example.com/api/v3/", expected: "https://github.example.com/api/graphql"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			client := github.NewClient(nil)
+			client.BaseURL, _ = url.Parse(tC.baseURL)
+
+			assert.Equal(t, tC.expected, (&githubProvider{client: client}).graphQLURL())
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package analyser
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/v39/github"
+)
+
+const (
+	checkRunName string = "Stylelia Cookstyle"
+	// GitHub takes at most this many annotations per request
+	maxAnnotations int = 50
+)
+
+// Providers that can publish the results of a run against a commit
+type checkPublisher interface {
+	PublishCheckRun(ctx context.Context, repo Repository, cookstyleVersion string, result CookstyleCheck) error
+}
+
+// Publishes result as a check on repo.LatestCommit
+func publishCheckRun(ctx context.Context, provider Provider, repo Repository, cookstyleVersion string, result CookstyleCheck) error {
+	publisher, ok := provider.(checkPublisher)
+	if !ok {
+		return fmt.Errorf("check runs are only supported on GitHub")
+	}
+
+	return publisher.PublishCheckRun(ctx, repo, cookstyleVersion, result)
+}
+
+// Creates a completed Check Run with the first batch of annotations, then
+// adds the rest in batches as GitHub appends annotations on every update
+func (g *githubProvider) PublishCheckRun(ctx context.Context, repo Repository, cookstyleVersion string, result CookstyleCheck) error {
+	annotations := checkRunAnnotations(result)
+	first := annotations
+	if len(first) > maxAnnotations {
+		first = first[:maxAnnotations]
+	}
+
+	conclusion := "success"
+	if result.Summary.OffenseCount > 0 {
+		conclusion = "neutral"
+	}
+
+	opts := github.CreateCheckRunOptions{
+		Name:        checkRunName,
+		HeadSHA:     repo.LatestCommit,
+		Status:      github.String("completed"),
+		Conclusion:  github.String(conclusion),
+		CompletedAt: &github.Timestamp{Time: time.Now()},
+		Output:      checkRunOutput(cookstyleVersion, result, first),
+	}
+	checkRun, _, err := g.client.Checks.CreateCheckRun(ctx, repo.Org, repo.Name, opts)
+	if err != nil {
+		return githubError(err)
+	}
+
+	for start := maxAnnotations; start < len(annotations); start += maxAnnotations {
+		end := start + maxAnnotations
+		if end > len(annotations) {
+			end = len(annotations)
+		}
+
+		update := github.UpdateCheckRunOptions{
+			Name:   checkRunName,
+			Output: checkRunOutput(cookstyleVersion, result, annotations[start:end]),
+		}
+		_, _, err = g.client.Checks.UpdateCheckRun(ctx, repo.Org, repo.Name, checkRun.GetID(), update)
+		if err != nil {
+			return githubError(err)
+		}
+	}
+
+	return nil
+}
+
+// Every update needs the title and summary alongside the annotations
+func checkRunOutput(cookstyleVersion string, result CookstyleCheck, annotations []*github.CheckRunAnnotation) *github.CheckRunOutput {
+	corrected := 0
+	for _, file := range result.Files {
+		for _, offense := range file.Offenses {
+			if offense.Corrected {
+				corrected++
+			}
+		}
+	}
+
+	summary := fmt.Sprintf("| Offences | Corrected | Files inspected | Target files |\n| --- | --- | --- | --- |\n| %d | %d | %d | %d |\n",
+		result.Summary.OffenseCount, corrected, result.Summary.InspectedFileCount, result.Summary.TargetFileCount)
+
+	return &github.CheckRunOutput{
+		Title:       github.String(fmt.Sprintf("Cookstyle %s found %d offences", cookstyleVersion, result.Summary.OffenseCount)),
+		Summary:     github.String(summary),
+		Annotations: annotations,
+	}
+}
+
+// One annotation per offense, in the order Cookstyle reported them
+func checkRunAnnotations(result CookstyleCheck) []*github.CheckRunAnnotation {
+	var annotations []*github.CheckRunAnnotation
+	for _, file := range result.Files {
+		for _, offense := range file.Offenses {
+			message := offense.Message
+			if offense.Corrected {
+				message += " (corrected)"
+			}
+
+			location := offense.Location
+			// GitHub needs a line, put offenses about the whole file on the first
+			if location.StartLine == 0 {
+				location = Location{StartLine: 1, LastLine: 1}
+			}
+			annotation := &github.CheckRunAnnotation{
+				Path:            github.String(file.Path),
+				StartLine:       github.Int(location.StartLine),
+				EndLine:         github.Int(location.LastLine),
+				AnnotationLevel: github.String(annotationLevel(offense.Severity)),
+				Title:           github.String(offense.CopName),
+				Message:         github.String(message),
+			}
+			// GitHub only takes columns for annotations on a single line
+			if location.StartLine == location.LastLine && location.StartColumn > 0 {
+				annotation.StartColumn = github.Int(location.StartColumn)
+				annotation.EndColumn = github.Int(location.LastColumn)
+			}
+
+			annotations = append(annotations, annotation)
+		}
+	}
+
+	return annotations
+}
+
+// Maps RuboCop severities onto the levels GitHub has
+func annotationLevel(severity string) string {
+	switch severity {
+	case "warning":
+		return "warning"
+	case "error", "fatal":
+		return "failure"
+	}
+
+	return "notice"
+}
@@ -0,0 +1,144 @@
+package analyser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-github/v39/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckRunAnnotations(t *testing.T) {
+	result := CookstyleCheck{
+		Files: []Files{
+			{
+				Path: "recipes/default.rb",
+				Offenses: []Offenses{
+					{
+						Severity:  "convention",
+						Message:   "Prefer single-quoted strings",
+						CopName:   "Style/StringLiterals",
+						Corrected: true,
+						Location:  Location{StartLine: 3, StartColumn: 9, LastLine: 3, LastColumn: 16},
+					},
+					{
+						Severity: "warning",
+						Message:  "Resource is never notified",
+						CopName:  "Chef/Correctness/Notifications",
+						Location: Location{StartLine: 5, StartColumn: 1, LastLine: 8, LastColumn: 3},
+					},
+				},
+			},
+			{
+				Path:     "metadata.rb",
+				Offenses: []Offenses{{Severity: "error", Message: "Missing license", CopName: "Chef/Sharing/LicenseMetadata"}},
+			},
+		},
+	}
+
+	expected := []*github.CheckRunAnnotation{
+		{
+			Path:            github.String("recipes/default.rb"),
+			StartLine:       github.Int(3),
+			EndLine:         github.Int(3),
+			StartColumn:     github.Int(9),
+			EndColumn:       github.Int(16),
+			AnnotationLevel: github.String("notice"),
+			Title:           github.String("Style/StringLiterals"),
+			Message:         github.String("Prefer single-quoted strings (corrected)"),
+		},
+		{
+			Path:            github.String("recipes/default.rb"),
+			StartLine:       github.Int(5),
+			EndLine:         github.Int(8),
+			AnnotationLevel: github.String("warning"),
+			Title:           github.String("Chef/Correctness/Notifications"),
+			Message:         github.String("Resource is never notified"),
+		},
+		{
+			Path:            github.String("metadata.rb"),
+			StartLine:       github.Int(1),
+			EndLine:         github.Int(1),
+			AnnotationLevel: github.String("failure"),
+			Title:           github.String("Chef/Sharing/LicenseMetadata"),
+			Message:         github.String("Missing license"),
+		},
+	}
+	assert.Equal(t, expected, checkRunAnnotations(result))
+}
+
+func TestPublishCheckRun(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRepo("stylelia", "snort", "main")
+	repo.LatestCommit = "b64d5bae3cee6da8c305c0f46f678914cb22e483"
+
+	t.Run("Creates the check run and adds the annotations in batches of 50", func(t *testing.T) {
+		var offenses []Offenses
+		for i := 1; i <= 120; i++ {
+			offenses = append(offenses, Offenses{Severity: "convention", Message: "Offense", CopName: "Style/Cop", Location: Location{StartLine: i, LastLine: i}})
+		}
+		result := CookstyleCheck{
+			Files:   []Files{{Path: "recipes/default.rb", Offenses: offenses}},
+			Summary: Summary{OffenseCount: 120, TargetFileCount: 4, InspectedFileCount: 4},
+		}
+
+		var created map[string]interface{}
+		var batches []int
+		mux := http.NewServeMux()
+		mux.HandleFunc("/repos/stylelia/snort/check-runs", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+			output := created["output"].(map[string]interface{})
+			batches = append(batches, len(output["annotations"].([]interface{})))
+			fmt.Fprint(w, `{"id": 42}`)
+		})
+		mux.HandleFunc("/repos/stylelia/snort/check-runs/42", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPatch, r.Method)
+			var update github.UpdateCheckRunOptions
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&update))
+			assert.Equal(t, "Cookstyle 7.25.0 found 120 offences", update.Output.GetTitle())
+			batches = append(batches, len(update.Output.Annotations))
+			fmt.Fprint(w, `{"id": 42}`)
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		provider := &githubProvider{client: newTestGithubClient(server)}
+
+		err := publishCheckRun(ctx, provider, repo, "7.25.0", result)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{50, 50, 20}, batches)
+		assert.Equal(t, "Stylelia Cookstyle", created["name"])
+		assert.Equal(t, repo.LatestCommit, created["head_sha"])
+		assert.Equal(t, "completed", created["status"])
+		assert.Equal(t, "neutral", created["conclusion"])
+		output := created["output"].(map[string]interface{})
+		assert.Equal(t, "| Offences | Corrected | Files inspected | Target files |\n| --- | --- | --- | --- |\n| 120 | 0 | 4 | 4 |\n", output["summary"])
+	})
+
+	t.Run("Succeeds without offenses", func(t *testing.T) {
+		var created map[string]interface{}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/repos/stylelia/snort/check-runs", func(w http.ResponseWriter, r *http.Request) {
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+			fmt.Fprint(w, `{"id": 42}`)
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		provider := &githubProvider{client: newTestGithubClient(server)}
+
+		err := publishCheckRun(ctx, provider, repo, "7.25.0", CookstyleCheck{})
+		assert.NoError(t, err)
+		assert.Equal(t, "success", created["conclusion"])
+	})
+
+	t.Run("Is only supported on GitHub", func(t *testing.T) {
+		gitlab := newGitlabProvider(&http.Client{}, GitlabServer{Token: "glToken"})
+
+		err := publishCheckRun(ctx, gitlab, repo, "7.25.0", CookstyleCheck{})
+		assert.EqualError(t, err, "check runs are only supported on GitHub")
+	})
+}
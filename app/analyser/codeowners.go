@@ -0,0 +1,156 @@
+package analyser
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Where GitHub, GitLab and Gitea look for a CODEOWNERS file, first one found wins
+var codeownersLocations = []string{
+	".github/CODEOWNERS",
+	".gitlab/CODEOWNERS",
+	"CODEOWNERS",
+	"docs/CODEOWNERS",
+}
+
+// A line of a CODEOWNERS file
+type codeownersRule struct {
+	pattern *regexp.Regexp
+	// Users as their login and teams as org/team, without the @
+	owners []string
+}
+
+// Returns the code owners of paths in the repository cloned into workDir.
+// paths have to be listed while they're staged, before they're committed.
+func reviewersFor(workDir string, paths []string) ([]string, error) {
+	rules, err := readCodeowners(workDir)
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+
+	return codeowners(rules, paths), nil
+}
+
+// Reads the CODEOWNERS file of the repository cloned into workDir, nil if it doesn't have one
+func readCodeowners(workDir string) ([]codeownersRule, error) {
+	for _, location := range codeownersLocations {
+		content, err := os.ReadFile(filepath.Join(workDir, filepath.FromSlash(location)))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return parseCodeowners(content), nil
+	}
+
+	return nil, nil
+}
+
+// Parses the rules of a CODEOWNERS file. Email owners are left out as
+// reviewers can only be requested by login.
+func parseCodeowners(content []byte) []codeownersRule {
+	var rules []codeownersRule
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		rule := codeownersRule{pattern: codeownersPattern(fields[0])}
+		for _, owner := range fields[1:] {
+			if strings.HasPrefix(owner, "#") {
+				break
+			}
+			if strings.HasPrefix(owner, "@") {
+				rule.owners = append(rule.owners, strings.TrimPrefix(owner, "@"))
+			}
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// Returns the owners of every path, the last matching rule owning a path
+func codeowners(rules []codeownersRule, paths []string) []string {
+	var owners []string
+	seen := make(map[string]bool)
+
+	for _, path := range paths {
+		for i := len(rules) - 1; i >= 0; i-- {
+			if !rules[i].pattern.MatchString(path) {
+				continue
+			}
+
+			for _, owner := range rules[i].owners {
+				if !seen[owner] {
+					seen[owner] = true
+					owners = append(owners, owner)
+				}
+			}
+			break
+		}
+	}
+
+	return owners
+}
+
+// Turns a gitignore style CODEOWNERS pattern into a regexp matching paths relative to the root
+func codeownersPattern(pattern string) *regexp.Regexp {
+	// Patterns with a slash anywhere but the end are relative to the root,
+	// anything else matches at any depth
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	directory := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(?:.*/)?")
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "/**/"):
+			b.WriteString("/(?:.*/)?")
+			i += 3
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+		case pattern[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+
+	lastSegment := pattern[strings.LastIndex(pattern, "/")+1:]
+	switch {
+	case directory:
+		// Everything inside the directory
+		b.WriteString("/.*")
+	case !strings.Contains(lastSegment, "*"):
+		// A file, or a directory and everything inside it. docs/* on the
+		// other hand only matches the files directly in docs.
+		b.WriteString("(?:/.*)?")
+	}
+	b.WriteString("$")
+
+	return regexp.MustCompile(b.String())
+}
@@ -0,0 +1,123 @@
+package analyser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodeownersPattern(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		pattern   string
+		matches   []string
+		unmatched []string
+	}{
+		{
+			desc:    "A wildcard matches everything",
+			pattern: "*",
+			matches: []string{"metadata.rb", "recipes/default.rb"},
+		},
+		{
+			desc:      "An extension matches at any depth",
+			pattern:   "*.rb",
+			matches:   []string{"metadata.rb", "recipes/default.rb"},
+			unmatched: []string{"README.md"},
+		},
+		{
+			desc:      "A bare name matches a file or directory at any depth",
+			pattern:   "recipes",
+			matches:   []string{"recipes/default.rb", "test/recipes/default.rb"},
+			unmatched: []string{"recipes.md"},
+		},
+		{
+			desc:      "A directory matches everything inside it",
+			pattern:   "recipes/",
+			matches:   []string{"recipes/default.rb", "recipes/sub/install.rb"},
+			unmatched: []string{"recipes"},
+		},
+		{
+			desc:      "A leading slash anchors to the root",
+			pattern:   "/recipes/",
+			matches:   []string{"recipes/default.rb"},
+			unmatched: []string{"test/recipes/default.rb"},
+		},
+		{
+			desc:      "A single star in a directory only matches its files",
+			pattern:   "docs/*",
+			matches:   []string{"docs/README.md"},
+			unmatched: []string{"docs/api/README.md"},
+		},
+		{
+			desc:      "A double star matches any number of directories",
+			pattern:   "test/**/default_test.rb",
+			matches:   []string{"test/default_test.rb", "test/integration/default/default_test.rb"},
+			unmatched: []string{"spec/default_test.rb"},
+		},
+		{
+			desc:      "A question mark matches one character",
+			pattern:   "libraries/helper?.rb",
+			matches:   []string{"libraries/helpers.rb"},
+			unmatched: []string{"libraries/helper.rb", "libraries/helper/s.rb"},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			pattern := codeownersPattern(tC.pattern)
+			for _, path := range tC.matches {
+				assert.True(t, pattern.MatchString(path), "%s should match %s", tC.pattern, path)
+			}
+			for _, path := range tC.unmatched {
+				assert.False(t, pattern.MatchString(path), "%s should not match %s", tC.pattern, path)
+			}
+		})
+	}
+}
+
+func TestCodeowners(t *testing.T) {
+	content := []byte(`# Everything else
+*                 @xorima @sous-chefs/maintainers
+
+*.rb              @youshy # Ruby
+/recipes/         @sous-chefs/chefs owner@example.com
+/recipes/legacy/
+`)
+	rules := parseCodeowners(content)
+	assert.Len(t, rules, 4)
+
+	t.Run("The last matching rule owns a path", func(t *testing.T) {
+		assert.Equal(t, []string{"youshy"}, codeowners(rules, []string{"metadata.rb"}))
+		assert.Equal(t, []string{"sous-chefs/chefs"}, codeowners(rules, []string{"recipes/default.rb"}))
+	})
+
+	t.Run("A rule without owners leaves the path unowned", func(t *testing.T) {
+		assert.Empty(t, codeowners(rules, []string{"recipes/legacy/old.rb"}))
+	})
+
+	t.Run("Collects the owners of every path once", func(t *testing.T) {
+		paths := []string{"README.md", "metadata.rb", "recipes/default.rb", "attributes/default.rb"}
+		expected := []string{"xorima", "sous-chefs/maintainers", "youshy", "sous-chefs/chefs"}
+		assert.Equal(t, expected, codeowners(rules, paths))
+	})
+}
+
+func TestReadCodeowners(t *testing.T) {
+	t.Run("Returns nothing without a CODEOWNERS file", func(t *testing.T) {
+		rules, err := readCodeowners(t.TempDir())
+		assert.NoError(t, err)
+		assert.Nil(t, rules)
+	})
+
+	t.Run("Prefers the file in .github", func(t *testing.T) {
+		workDir := t.TempDir()
+		assert.NoError(t, os.MkdirAll(filepath.Join(workDir, ".github"), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(workDir, ".github", "CODEOWNERS"), []byte("* @xorima\n"), 0644))
+		assert.NoError(t, os.WriteFile(filepath.Join(workDir, "CODEOWNERS"), []byte("* @youshy\n"), 0644))
+
+		rules, err := readCodeowners(workDir)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"xorima"}, codeowners(rules, []string{"metadata.rb"}))
+	})
+}
@@ -0,0 +1,254 @@
+package analyser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v39/github"
+)
+
+// Lines of a comment starting with this are commands for Stylelia
+const commandMention string = "@stylelia"
+
+// Commands maintainers can leave on Stylelia's Pull Requests
+const (
+	// Runs again against the latest commit on the default branch
+	commandRebase string = "rebase"
+	// Closes the Pull Request and raises it again from scratch
+	commandRecreate string = "recreate"
+	// Turns a cop off in the repository's .rubocop.yml, followed by the cop
+	commandIgnoreCop string = "ignore cop"
+	// Closes the Pull Request and doesn't raise it again until the next release
+	commandIgnoreVersion string = "ignore version"
+)
+
+var errNoCommand = errors.New("no command for Stylelia")
+
+// Cop names are departments and the cop in CamelCase, e.g. Chef/Style/CommentFormat
+var copPattern = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*(/[A-Z][A-Za-z0-9]*)+$`)
+
+// A comment on a Pull Request with a command for Stylelia
+type Comment struct {
+	Organisation string
+	Name         string
+	// Number of the Pull Request
+	Number int
+	// Login of whoever left the comment
+	User string
+	Body string
+}
+
+// A command read from a comment, Cop is only set for commandIgnoreCop
+type command struct {
+	Action string
+	Cop    string
+}
+
+// Reads the command from the first line of body mentioning Stylelia
+func parseCommand(body string) (command, error) {
+	for _, line := range strings.Split(body, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !strings.EqualFold(fields[0], commandMention) {
+			continue
+		}
+
+		action := strings.ToLower(strings.Join(fields[1:], " "))
+		switch action {
+		case commandRebase, commandRecreate, commandIgnoreVersion:
+			return command{Action: action}, nil
+		}
+
+		if len(fields) == 4 && strings.ToLower(fields[1]+" "+fields[2]) == commandIgnoreCop {
+			if !copPattern.MatchString(fields[3]) {
+				return command{}, fmt.Errorf("%q is not the name of a cop", fields[3])
+			}
+			return command{Action: commandIgnoreCop, Cop: fields[3]}, nil
+		}
+
+		return command{}, fmt.Errorf("%q is not a command", action)
+	}
+
+	return command{}, errNoCommand
+}
+
+// Carries out the command in comment and replies with the outcome
+func (h *Handler) command(ctx context.Context, comment Comment) error {
+	return h.runCommand(ctx, comment, h.handle)
+}
+
+// Same as command, running the analyser again with run. Comments on Pull
+// Requests other than Stylelia's are ignored.
+func (h *Handler) runCommand(ctx context.Context, comment Comment, run func(context.Context, Event) (Result, error)) error {
+	org := comment.Organisation
+	name := comment.Name
+
+	client, err := h.githubClient(ctx, org, name)
+	if err != nil {
+		h.Log.Errorf("Unable to create GitHub client: %v", err)
+		return err
+	}
+
+	pr, _, err := client.PullRequests.Get(ctx, org, name, comment.Number)
+	if err != nil {
+		h.Log.Errorf("Unable to get PR: %v", err)
+		return githubError(err)
+	}
+	if !strings.HasPrefix(pr.GetHead().GetRef(), cookstyleBranchPrefix) {
+		h.Log.Debugf("Ignoring comment on %s, it isn't a Stylelia PR", pr.GetHTMLURL())
+		return nil
+	}
+
+	permission, _, err := client.Repositories.GetPermissionLevel(ctx, org, name, comment.User)
+	if err != nil {
+		h.Log.Errorf("Unable to get permission level: %v", err)
+		return githubError(err)
+	}
+	if !canPush(permission.GetPermission()) {
+		h.Log.Infof("Ignoring command from %s, who can't push to %s/%s", comment.User, org, name)
+		return h.reply(ctx, client, comment, fmt.Sprintf("Sorry @%s, only people who can push to this repository can give Stylelia commands.", comment.User))
+	}
+
+	cmd, err := parseCommand(comment.Body)
+	if err != nil {
+		return h.reply(ctx, client, comment, fmt.Sprintf("Sorry @%s, %v. Stylelia understands `%s rebase`, `%s recreate`, `%s ignore cop <cop>` and `%s ignore version`.", comment.User, err, commandMention, commandMention, commandMention, commandMention))
+	}
+	h.Log.Infof("Running %q from %s on %s", cmd.Action, comment.User, pr.GetHTMLURL())
+
+	provider, err := h.provider(ctx, org, name)
+	if err != nil {
+		h.Log.Errorf("Unable to create provider: %v", err)
+		return err
+	}
+
+	repo := NewRepo(org, name, pr.GetBase().GetRef())
+	head := pr.GetHead().GetRepo()
+	if head.GetID() != pr.GetBase().GetRepo().GetID() {
+		fork := NewRepo(head.GetOwner().GetLogin(), head.GetName(), "")
+		repo.Fork = &fork
+	}
+	change := pullRequestChange(pr)
+	event := Event{Organisation: org, Name: name, Force: true}
+
+	switch cmd.Action {
+	case commandRebase:
+		outcome, err := h.rerun(ctx, provider, repo, change, event, run)
+		if err != nil {
+			h.Log.Errorf("Unable to rebase: %v", err)
+			return h.replyFailed(ctx, client, comment, cmd)
+		}
+		return h.reply(ctx, client, comment, outcome)
+
+	case commandRecreate:
+		// Should raising it again fail, the closed PR mustn't count as declined
+		version := strings.TrimPrefix(change.Branch, cookstyleBranchPrefix)
+		err = h.Store.UpdateRecreatedVersion(ctx, org, name, Cookstyle, version)
+		if err != nil {
+			h.Log.Errorf("Unable to update recreated version in Redis: %v", err)
+			return h.replyFailed(ctx, client, comment, cmd)
+		}
+
+		closing := fmt.Sprintf("Closing this PR to raise it again, as @%s asked.", comment.User)
+		err = provider.CloseChangeRequest(ctx, repo, change, closing)
+		if err != nil {
+			h.Log.Errorf("Unable to close PR: %v", err)
+			return h.replyFailed(ctx, client, comment, cmd)
+		}
+
+		outcome, err := h.rerun(ctx, provider, repo, change, event, run)
+		if err != nil {
+			h.Log.Errorf("Unable to recreate: %v", err)
+			return h.replyFailed(ctx, client, comment, cmd)
+		}
+		return h.reply(ctx, client, comment, outcome)
+
+	case commandIgnoreCop:
+		cops, err := h.Store.GetIgnoredCops(ctx, org, name, Cookstyle)
+		if err != nil {
+			h.Log.Errorf("Unable to get ignored cops from Redis: %v", err)
+			return h.replyFailed(ctx, client, comment, cmd)
+		}
+		if !containsFold(cops, cmd.Cop) {
+			err = h.Store.UpdateIgnoredCops(ctx, org, name, Cookstyle, append(cops, cmd.Cop))
+			if err != nil {
+				h.Log.Errorf("Unable to update ignored cops in Redis: %v", err)
+				return h.replyFailed(ctx, client, comment, cmd)
+			}
+		}
+
+		outcome, err := h.rerun(ctx, provider, repo, change, event, run)
+		if err != nil {
+			h.Log.Errorf("Unable to run without %s: %v", cmd.Cop, err)
+			return h.replyFailed(ctx, client, comment, cmd)
+		}
+		return h.reply(ctx, client, comment, fmt.Sprintf("Stylelia leaves `%s` alone from now on, its PRs turn it off in `%s`. %s", cmd.Cop, rubocopConfig, outcome))
+
+	case commandIgnoreVersion:
+		version := strings.TrimPrefix(change.Branch, cookstyleBranchPrefix)
+		err = h.Store.UpdateDeclinedVersion(ctx, org, name, Cookstyle, version)
+		if err != nil {
+			h.Log.Errorf("Unable to update declined version in Redis: %v", err)
+			return h.replyFailed(ctx, client, comment, cmd)
+		}
+
+		// Closing leaves the comment, so there's nothing else to reply
+		closing := fmt.Sprintf("Ignoring Cookstyle %s, as @%s asked. Stylelia raises a PR again for the next release.", version, comment.User)
+		err = provider.CloseChangeRequest(ctx, repo, change, closing)
+		if err != nil {
+			h.Log.Errorf("Unable to close PR: %v", err)
+			return h.replyFailed(ctx, client, comment, cmd)
+		}
+		h.Log.Infof("PR Closed! %s", change.URL)
+	}
+
+	return nil
+}
+
+// Runs the analyser again and describes where the fixes ended up
+func (h *Handler) rerun(ctx context.Context, provider Provider, repo Repository, change *ChangeRequest, event Event, run func(context.Context, Event) (Result, error)) (string, error) {
+	_, err := run(ctx, event)
+	if err != nil {
+		return "", err
+	}
+
+	changes, err := provider.ListChangeRequests(ctx, repo, cookstyleBranchPrefix)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case len(changes) == 0:
+		return fmt.Sprintf("Cookstyle found nothing to fix on the latest commit on `%s`.", repo.DefaultBranch), nil
+	case changes[0].Number == change.Number:
+		return fmt.Sprintf("This PR is up to date with the latest commit on `%s`.", repo.DefaultBranch), nil
+	default:
+		return fmt.Sprintf("The fixes are in %s now.", changes[0].URL), nil
+	}
+}
+
+func (h *Handler) reply(ctx context.Context, client *github.Client, comment Comment, body string) error {
+	_, _, err := client.Issues.CreateComment(ctx, comment.Organisation, comment.Name, comment.Number, &github.IssueComment{Body: &body})
+	if err != nil {
+		h.Log.Errorf("Unable to reply to comment: %v", err)
+		return githubError(err)
+	}
+
+	return nil
+}
+
+// Errors can carry details of the deployment, so they're only logged
+func (h *Handler) replyFailed(ctx context.Context, client *github.Client, comment Comment, cmd command) error {
+	return h.reply(ctx, client, comment, fmt.Sprintf("Sorry @%s, `%s %s` failed, the analyser's logs have the details.", comment.User, commandMention, cmd.Action))
+}
+
+// Whether a permission level lets a user push, GitHub reports maintain as write
+func canPush(permission string) bool {
+	switch permission {
+	case "admin", "maintain", "write":
+		return true
+	}
+
+	return false
+}
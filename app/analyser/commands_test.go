@@ -0,0 +1,245 @@
+package analyser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/youshy/logger"
+)
+
+func TestParseCommand(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		body     string
+		expected command
+		err      string
+	}{
+		{desc: "Reads a rebase", body: "@stylelia rebase", expected: command{Action: commandRebase}},
+		{desc: "Ignores case and extra spaces", body: "@Stylelia   ReCreate ", expected: command{Action: commandRecreate}},
+		{desc: "Reads the cop to ignore", body: "@stylelia ignore cop Chef/Style/CommentFormat", expected: command{Action: commandIgnoreCop, Cop: "Chef/Style/CommentFormat"}},
+		{desc: "Reads a command after other lines", body: "Thanks!\r\n\r\n@stylelia ignore version\r\n", expected: command{Action: commandIgnoreVersion}},
+		{desc: "Only reads mentions starting a line", body: "Ask @stylelia rebase", err: errNoCommand.Error()},
+		{desc: "Errors for an unknown command", body: "@stylelia merge", err: `"merge" is not a command`},
+		{desc: "Errors for something that isn't a cop", body: "@stylelia ignore cop everything", err: `"everything" is not the name of a cop`},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			actual, err := parseCommand(tC.body)
+			if tC.err != "" {
+				assert.EqualError(t, err, tC.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tC.expected, actual)
+		})
+	}
+}
+
+// Adds the ignored cops to the declined versions
+type fakeCommandStore struct {
+	*fakeDeclinedStore
+	cops []string
+}
+
+func (f *fakeCommandStore) GetIgnoredCops(ctx context.Context, org, name, tool string) ([]string, error) {
+	return f.cops, nil
+}
+
+func (f *fakeCommandStore) UpdateIgnoredCops(ctx context.Context, org, name, tool string, cops []string) error {
+	f.cops = cops
+	return nil
+}
+
+// A GitHub server with Stylelia's PR 7, someone else's PR 8, xorima who can
+// push and youshy who can't. Comments and closed PRs are recorded.
+type fakeCommandServer struct {
+	*httptest.Server
+	comments []string
+	closed   []int
+	// Open Stylelia PRs once the analyser has run again
+	open string
+}
+
+func newFakeCommandServer(t *testing.T) *fakeCommandServer {
+	fake := &fakeCommandServer{open: `[]`}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/repos/stylelia/snort/pulls/7", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch {
+			fake.closed = append(fake.closed, 7)
+		}
+		fmt.Fprint(w, `{"number": 7, "html_url": "https://github.com/stylelia/snort/pull/7", "head": {"ref": "stylelia/cookstyle_7.25.6", "repo": {"id": 1}}, "base": {"ref": "main", "repo": {"id": 1}}}`)
+	})
+	mux.HandleFunc("/api/v3/repos/stylelia/snort/pulls/8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"number": 8, "head": {"ref": "feature", "repo": {"id": 1}}, "base": {"ref": "main", "repo": {"id": 1}}}`)
+	})
+	mux.HandleFunc("/api/v3/repos/stylelia/snort/pulls", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, fake.open)
+	})
+	mux.HandleFunc("/api/v3/repos/stylelia/snort/collaborators/xorima/permission", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"permission": "write"}`)
+	})
+	mux.HandleFunc("/api/v3/repos/stylelia/snort/collaborators/youshy/permission", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"permission": "read"}`)
+	})
+	mux.HandleFunc("/api/v3/repos/stylelia/snort/issues/7/comments", func(w http.ResponseWriter, r *http.Request) {
+		var comment struct {
+			Body string `json:"body"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&comment))
+		fake.comments = append(fake.comments, comment.Body)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{}`)
+	})
+	mux.HandleFunc("/api/v3/repos/stylelia/snort/git/refs/heads/stylelia/cookstyle_7.25.6", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodDelete, r.Method)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	fake.Server = httptest.NewServer(mux)
+	return fake
+}
+
+func TestHandlerRunCommand(t *testing.T) {
+	ctx := context.Background()
+	newHandler := func(server *fakeCommandServer, store KeyValueStore) Handler {
+		return Handler{
+			Client: &http.Client{},
+			Log:    logger.NewLogger(logger.DEBUG, false),
+			Tokens: staticToken("privateToken"),
+			Store:  store,
+			Config: Config{GithubApiURL: server.URL},
+		}
+	}
+	newStore := func() *fakeCommandStore {
+		return &fakeCommandStore{fakeDeclinedStore: &fakeDeclinedStore{versions: make(map[string]string)}}
+	}
+	// Records the events the analyser would have run
+	newRun := func(events *[]Event) func(context.Context, Event) (Result, error) {
+		return func(ctx context.Context, event Event) (Result, error) {
+			*events = append(*events, event)
+			return Result{}, nil
+		}
+	}
+	comment := func(user, body string) Comment {
+		return Comment{Organisation: "stylelia", Name: "snort", Number: 7, User: user, Body: body}
+	}
+
+	t.Run("A rebase runs the analyser again", func(t *testing.T) {
+		server := newFakeCommandServer(t)
+		defer server.Close()
+		server.open = `[{"number": 7, "head": {"ref": "stylelia/cookstyle_7.25.6", "repo": {"id": 1}}, "base": {"repo": {"id": 1}}}]`
+		handler := newHandler(server, newStore())
+
+		var events []Event
+		err := handler.runCommand(ctx, comment("xorima", "@stylelia rebase"), newRun(&events))
+		assert.NoError(t, err)
+		assert.Equal(t, []Event{{Organisation: "stylelia", Name: "snort", Force: true}}, events)
+		assert.Equal(t, []string{"This PR is up to date with the latest commit on `main`."}, server.comments)
+	})
+
+	t.Run("A recreate closes the PR before running the analyser again", func(t *testing.T) {
+		server := newFakeCommandServer(t)
+		defer server.Close()
+		server.open = `[{"number": 9, "html_url": "https://github.com/stylelia/snort/pull/9", "head": {"ref": "stylelia/cookstyle_7.25.6", "repo": {"id": 1}}, "base": {"repo": {"id": 1}}}]`
+		store := newStore()
+		handler := newHandler(server, store)
+
+		var events []Event
+		err := handler.runCommand(ctx, comment("xorima", "@stylelia recreate"), newRun(&events))
+		assert.NoError(t, err)
+		assert.Len(t, events, 1)
+		assert.Equal(t, "7.25.6", store.versions["recreated/stylelia/snort/Cookstyle"])
+		assert.Equal(t, []int{7}, server.closed)
+		assert.Equal(t, []string{
+			"Closing this PR to raise it again, as @xorima asked.",
+			"The fixes are in https://github.com/stylelia/snort/pull/9 now.",
+		}, server.comments)
+	})
+
+	t.Run("Ignoring a cop keeps it for later runs", func(t *testing.T) {
+		server := newFakeCommandServer(t)
+		defer server.Close()
+		store := newStore()
+		store.cops = []string{"Chef/Modernize/FoodcriticComments"}
+		handler := newHandler(server, store)
+
+		var events []Event
+		err := handler.runCommand(ctx, comment("xorima", "@stylelia ignore cop Chef/Style/CommentFormat"), newRun(&events))
+		assert.NoError(t, err)
+		assert.Len(t, events, 1)
+		assert.Equal(t, []string{"Chef/Modernize/FoodcriticComments", "Chef/Style/CommentFormat"}, store.cops)
+		assert.Equal(t, []string{"Stylelia leaves `Chef/Style/CommentFormat` alone from now on, its PRs turn it off in `.rubocop.yml`. Cookstyle found nothing to fix on the latest commit on `main`."}, server.comments)
+	})
+
+	t.Run("Ignoring the version declines it and closes the PR", func(t *testing.T) {
+		server := newFakeCommandServer(t)
+		defer server.Close()
+		store := newStore()
+		handler := newHandler(server, store)
+
+		var events []Event
+		err := handler.runCommand(ctx, comment("xorima", "@stylelia ignore version"), newRun(&events))
+		assert.NoError(t, err)
+		assert.Empty(t, events)
+		assert.Equal(t, "7.25.6", store.versions["stylelia/snort/Cookstyle"])
+		assert.Equal(t, []int{7}, server.closed)
+		assert.Equal(t, []string{"Ignoring Cookstyle 7.25.6, as @xorima asked. Stylelia raises a PR again for the next release."}, server.comments)
+	})
+
+	t.Run("Only people who can push give commands", func(t *testing.T) {
+		server := newFakeCommandServer(t)
+		defer server.Close()
+		handler := newHandler(server, newStore())
+
+		var events []Event
+		err := handler.runCommand(ctx, comment("youshy", "@stylelia rebase"), newRun(&events))
+		assert.NoError(t, err)
+		assert.Empty(t, events)
+		assert.Equal(t, []string{"Sorry @youshy, only people who can push to this repository can give Stylelia commands."}, server.comments)
+	})
+
+	t.Run("Replies to an unknown command", func(t *testing.T) {
+		server := newFakeCommandServer(t)
+		defer server.Close()
+		handler := newHandler(server, newStore())
+
+		var events []Event
+		err := handler.runCommand(ctx, comment("xorima", "@stylelia merge"), newRun(&events))
+		assert.NoError(t, err)
+		assert.Empty(t, events)
+		assert.Equal(t, []string{"Sorry @xorima, \"merge\" is not a command. Stylelia understands `@stylelia rebase`, `@stylelia recreate`, `@stylelia ignore cop <cop>` and `@stylelia ignore version`."}, server.comments)
+	})
+
+	t.Run("Replies when the analyser fails", func(t *testing.T) {
+		server := newFakeCommandServer(t)
+		defer server.Close()
+		handler := newHandler(server, newStore())
+
+		run := func(ctx context.Context, event Event) (Result, error) {
+			return Result{}, fmt.Errorf("exit status 128")
+		}
+		err := handler.runCommand(ctx, comment("xorima", "@stylelia rebase"), run)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Sorry @xorima, `@stylelia rebase` failed, the analyser's logs have the details."}, server.comments)
+	})
+
+	t.Run("Comments on other PRs are ignored", func(t *testing.T) {
+		server := newFakeCommandServer(t)
+		defer server.Close()
+		handler := newHandler(server, newStore())
+
+		var events []Event
+		other := comment("xorima", "@stylelia rebase")
+		other.Number = 8
+		err := handler.runCommand(ctx, other, newRun(&events))
+		assert.NoError(t, err)
+		assert.Empty(t, events)
+		assert.Empty(t, server.comments)
+	})
+}
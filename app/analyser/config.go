@@ -0,0 +1,425 @@
+package analyser
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Settings for a deployment of the analyser
+// Loaded once up front so a missing setting stops the run before any work is done
+type Config struct {
+	// Default repository for events that don't name one
+	Organisation string `yaml:"organisation"`
+	Name         string `yaml:"name"`
+
+	RedisHost     string `yaml:"redis_host"`
+	RedisPort     uint16 `yaml:"redis_port"`
+	RedisPassword string `yaml:"redis_password"`
+
+	GithubToken string `yaml:"github_token"`
+	GitEmail    string `yaml:"git_email"`
+	GitUsername string `yaml:"git_username"`
+	// How fixes reach the branch, CommitWithGit (the default) or CommitWithAPI
+	CommitMethod string `yaml:"commit_method"`
+	// Push to a fork under the bot's account for repositories it can only read
+	Forks bool `yaml:"forks"`
+
+	// Added to every Pull Request, reviewers come from the repository's CODEOWNERS
+	PullRequestLabels    []string `yaml:"pull_request_labels"`
+	PullRequestAssignees []string `yaml:"pull_request_assignees"`
+	// Repositories, or whole organisations, that merge their Pull Requests
+	// once the checks pass, keyed by org or org/name with the merge method
+	AutoMerge map[string]string `yaml:"auto_merge"`
+	// Only report through check runs, commit statuses and tracking issues,
+	// never pushing fixes or raising Pull Requests
+	SkipPullRequests bool `yaml:"skip_pull_requests"`
+	// Overrides SkipPullRequests for repositories, or whole organisations,
+	// keyed by org or org/name with whether to raise Pull Requests
+	PullRequests map[string]bool `yaml:"pull_requests"`
+	// Publish every run as a Check Run on the commit analysed, needs a GitHub App
+	CheckRuns bool `yaml:"check_runs"`
+	// Set a commit status on the commit analysed, failing while there are offences
+	CommitStatuses bool `yaml:"commit_statuses"`
+	// Keep an issue open listing the offences Cookstyle can't correct
+	TrackingIssues bool `yaml:"tracking_issues"`
+
+	// Set to run as a GitHub App rather than with GithubToken
+	GithubAppID int64 `yaml:"github_app_id"`
+	// PEM encoded key, either inline or in a file
+	GithubAppPrivateKey     string `yaml:"github_app_private_key"`
+	GithubAppPrivateKeyPath string `yaml:"github_app_private_key_path"`
+
+	// GitHub Enterprise Server, left empty for github.com
+	GithubApiURL    string `yaml:"github_api_url"`
+	GithubUploadURL string `yaml:"github_upload_url"`
+	GitHost         string `yaml:"git_host"`
+	// Overrides of the above for repositories on another server, keyed by
+	// organisation or by org/name
+	GithubServers map[string]GithubServer `yaml:"github_servers"`
+	// Organisations, or org/name, hosted on GitLab rather than GitHub
+	GitlabServers map[string]GitlabServer `yaml:"gitlab_servers"`
+	// Organisations, or org/name, hosted on Gitea or Forgejo
+	GiteaServers map[string]GiteaServer `yaml:"gitea_servers"`
+
+	// Only needed when receiving webhooks
+	WebhookSecret string `yaml:"webhook_secret"`
+	// SQS queue a release sends an event per repository to, rather than
+	// running them all in one invocation
+	ReleaseQueueURL string `yaml:"release_queue_url"`
+}
+
+// Reads the config from the YAML file at path, if given, then fills in
+// anything the file left out from the environment
+func LoadConfig(path string) (Config, error) {
+	var config Config
+
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return config, err
+		}
+
+		err = yaml.Unmarshal(content, &config)
+		if err != nil {
+			return config, fmt.Errorf("config: %s: %v", path, err)
+		}
+	}
+
+	err := config.fromEnv()
+	return config, err
+}
+
+// Loads the config from the file named in STYLELIA_CONFIG and the environment
+func LoadConfigFromEnv() (Config, error) {
+	return LoadConfig(os.Getenv("STYLELIA_CONFIG"))
+}
+
+func (c *Config) fromEnv() error {
+	setFromEnv(&c.Organisation, "ORGANISATION")
+	setFromEnv(&c.Name, "NAME")
+	setFromEnv(&c.RedisHost, "REDIS_HOST")
+	setFromEnv(&c.RedisPassword, "REDIS_PASSWORD")
+	setFromEnv(&c.GithubToken, "GITHUB_TOKEN")
+	setFromEnv(&c.GitEmail, "GIT_EMAIL")
+	setFromEnv(&c.GitUsername, "GIT_USERNAME")
+	setFromEnv(&c.CommitMethod, "COMMIT_METHOD")
+	setListFromEnv(&c.PullRequestLabels, "PULL_REQUEST_LABELS")
+	setListFromEnv(&c.PullRequestAssignees, "PULL_REQUEST_ASSIGNEES")
+	setFromEnv(&c.WebhookSecret, "WEBHOOK_SECRET")
+	setFromEnv(&c.ReleaseQueueURL, "RELEASE_QUEUE_URL")
+	setFromEnv(&c.GithubAppPrivateKey, "GITHUB_APP_PRIVATE_KEY")
+	setFromEnv(&c.GithubAppPrivateKeyPath, "GITHUB_APP_PRIVATE_KEY_PATH")
+	setFromEnv(&c.GithubApiURL, "GITHUB_API_URL")
+	setFromEnv(&c.GithubUploadURL, "GITHUB_UPLOAD_URL")
+	setFromEnv(&c.GitHost, "GIT_HOST")
+
+	err := setBoolFromEnv(&c.Forks, "FORKS")
+	if err != nil {
+		return err
+	}
+	err = setBoolFromEnv(&c.SkipPullRequests, "SKIP_PULL_REQUESTS")
+	if err != nil {
+		return err
+	}
+	err = setBoolFromEnv(&c.CheckRuns, "CHECK_RUNS")
+	if err != nil {
+		return err
+	}
+	err = setBoolFromEnv(&c.CommitStatuses, "COMMIT_STATUSES")
+	if err != nil {
+		return err
+	}
+	err = setBoolFromEnv(&c.TrackingIssues, "TRACKING_ISSUES")
+	if err != nil {
+		return err
+	}
+
+	appIDRaw := os.Getenv("GITHUB_APP_ID")
+	if c.GithubAppID == 0 && appIDRaw != "" {
+		appID, err := strconv.ParseInt(appIDRaw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("config: GITHUB_APP_ID %q is not a valid app id", appIDRaw)
+		}
+		c.GithubAppID = appID
+	}
+
+	portRaw := os.Getenv("REDIS_PORT")
+	if c.RedisPort == 0 && portRaw != "" {
+		port, err := strconv.ParseUint(portRaw, 10, 16)
+		if err != nil {
+			return fmt.Errorf("config: REDIS_PORT %q is not a valid port", portRaw)
+		}
+		c.RedisPort = uint16(port)
+	}
+
+	return nil
+}
+
+// Returns the GitHub App private key, reading it from disk if it was given as a path
+func (c Config) githubAppPrivateKey() ([]byte, error) {
+	if c.GithubAppPrivateKey != "" {
+		return []byte(c.GithubAppPrivateKey), nil
+	}
+
+	return os.ReadFile(c.GithubAppPrivateKeyPath)
+}
+
+func setFromEnv(field *string, key string) {
+	if *field == "" {
+		*field = os.Getenv(key)
+	}
+}
+
+// Only turns a setting on, as the file can't tell false apart from left out
+func setBoolFromEnv(field *bool, key string) error {
+	value := os.Getenv(key)
+	if *field || value == "" {
+		return nil
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("config: %s %q is not true or false", key, value)
+	}
+	*field = enabled
+	return nil
+}
+
+// Reads a comma separated list
+func setListFromEnv(field *[]string, key string) {
+	value := os.Getenv(key)
+	if len(*field) > 0 || value == "" {
+		return
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			*field = append(*field, item)
+		}
+	}
+}
+
+// Checks every setting needed to analyse a repository, listing all that are missing
+func (c Config) Validate() error {
+	err := missingSettingsError(c.missing(c.onGithub()))
+	if err != nil {
+		return err
+	}
+
+	return c.validateSettings()
+}
+
+// Same as Validate, also checking the settings needed to receive webhooks
+func (c Config) ValidateServer() error {
+	// Webhooks only come from GitHub
+	missing := c.missing(true)
+	if c.WebhookSecret == "" {
+		missing = append(missing, "WEBHOOK_SECRET")
+	}
+
+	err := missingSettingsError(missing)
+	if err != nil {
+		return err
+	}
+
+	return c.validateSettings()
+}
+
+// Checks the settings that are there have sensible values
+func (c Config) validateSettings() error {
+	switch c.CommitMethod {
+	case "", CommitWithGit, CommitWithAPI:
+	default:
+		return fmt.Errorf("config: COMMIT_METHOD %q is not one of %s or %s", c.CommitMethod, CommitWithGit, CommitWithAPI)
+	}
+
+	// GitHub only lets apps create check runs, a token gets a 403 on every run
+	if c.CheckRuns && c.GithubAppID == 0 {
+		return fmt.Errorf("config: CHECK_RUNS needs Stylelia running as a GitHub App, GITHUB_APP_ID is missing")
+	}
+
+	for key, method := range c.AutoMerge {
+		switch method {
+		case MergeWithMerge, MergeWithSquash, MergeWithRebase:
+		default:
+			return fmt.Errorf("config: auto_merge: %s: %q is not one of %s, %s or %s", key, method, MergeWithMerge, MergeWithSquash, MergeWithRebase)
+		}
+	}
+
+	return c.validateServers()
+}
+
+// Returns the server a repository lives on, name may be empty to look up an organisation
+func (c Config) githubServer(org, name string) GithubServer {
+	if name != "" {
+		server, ok := c.GithubServers[org+"/"+name]
+		if ok {
+			return server
+		}
+	}
+
+	server, ok := c.GithubServers[org]
+	if ok {
+		return server
+	}
+
+	return c.defaultGithubServer()
+}
+
+// Returns the GitLab server a repository lives on, if it's on GitLab at all
+func (c Config) gitlabServer(org, name string) (GitlabServer, bool) {
+	if name != "" {
+		server, ok := c.GitlabServers[org+"/"+name]
+		if ok {
+			return server, true
+		}
+	}
+
+	server, ok := c.GitlabServers[org]
+	return server, ok
+}
+
+// Returns the Gitea server a repository lives on, if it's on Gitea at all
+func (c Config) giteaServer(org, name string) (GiteaServer, bool) {
+	if name != "" {
+		server, ok := c.GiteaServers[org+"/"+name]
+		if ok {
+			return server, true
+		}
+	}
+
+	server, ok := c.GiteaServers[org]
+	return server, ok
+}
+
+// Returns the merge method of a repository that opted into auto-merge
+func (c Config) autoMergeMethod(org, name string) (string, bool) {
+	method, ok := c.AutoMerge[org+"/"+name]
+	if ok {
+		return method, true
+	}
+
+	method, ok = c.AutoMerge[org]
+	return method, ok
+}
+
+// Whether fixes are pushed and raised as Pull Requests for a repository
+func (c Config) raisesPullRequests(org, name string) bool {
+	raises, ok := c.PullRequests[org+"/"+name]
+	if ok {
+		return raises
+	}
+
+	raises, ok = c.PullRequests[org]
+	if ok {
+		return raises
+	}
+
+	return !c.SkipPullRequests
+}
+
+// The server set for the whole deployment
+func (c Config) defaultGithubServer() GithubServer {
+	return GithubServer{ApiURL: c.GithubApiURL, UploadURL: c.GithubUploadURL, GitHost: c.GitHost}
+}
+
+func (c Config) validateServers() error {
+	err := c.defaultGithubServer().validate()
+	if err != nil {
+		return fmt.Errorf("config: %v", err)
+	}
+
+	for key, server := range c.GithubServers {
+		err := server.validate()
+		if err != nil {
+			return fmt.Errorf("config: github_servers: %s: %v", key, err)
+		}
+
+		// The deployment's token or app only works on its own server
+		if server.Token == "" && strings.TrimSuffix(server.ApiURL, "/") != strings.TrimSuffix(c.GithubApiURL, "/") {
+			apiURL := server.ApiURL
+			if apiURL == "" {
+				apiURL = "https://api.github.com/"
+			}
+			return fmt.Errorf("config: github_servers: %s: missing token for %s", key, apiURL)
+		}
+	}
+
+	for key, server := range c.GitlabServers {
+		err := server.validate()
+		if err != nil {
+			return fmt.Errorf("config: gitlab_servers: %s: %v", key, err)
+		}
+	}
+
+	for key, server := range c.GiteaServers {
+		err := server.validate()
+		if err != nil {
+			return fmt.Errorf("config: gitea_servers: %s: %v", key, err)
+		}
+	}
+
+	return nil
+}
+
+func missingSettingsError(missing []string) error {
+	if len(missing) > 0 {
+		return fmt.Errorf("config: missing settings: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// Whether any repository is analysed with the deployment's GitHub
+// credentials. Deployments without a default repository that only list
+// GitLab and Gitea servers run on those alone.
+func (c Config) onGithub() bool {
+	for _, server := range c.GithubServers {
+		if server.Token == "" {
+			return true
+		}
+	}
+
+	if c.Organisation == "" {
+		return len(c.GitlabServers) == 0 && len(c.GiteaServers) == 0
+	}
+
+	_, gitlab := c.gitlabServer(c.Organisation, c.Name)
+	_, gitea := c.giteaServer(c.Organisation, c.Name)
+	return !gitlab && !gitea
+}
+
+// Lists the settings missing, github is whether GitHub credentials are needed
+func (c Config) missing(github bool) []string {
+	var missing []string
+	if c.RedisHost == "" {
+		missing = append(missing, "REDIS_HOST")
+	}
+	if c.RedisPort == 0 {
+		missing = append(missing, "REDIS_PORT")
+	}
+	if c.GithubAppID != 0 {
+		if c.GithubAppPrivateKey == "" && c.GithubAppPrivateKeyPath == "" {
+			missing = append(missing, "GITHUB_APP_PRIVATE_KEY")
+		}
+	} else if github && c.GithubToken == "" {
+		missing = append(missing, "GITHUB_TOKEN")
+	}
+	// Commits made through the API are authored by the token's owner, GitLab
+	// and Gitea can't commit through the API so are pushed to with git
+	if c.CommitMethod != CommitWithAPI || len(c.GitlabServers) > 0 || len(c.GiteaServers) > 0 {
+		if c.GitEmail == "" {
+			missing = append(missing, "GIT_EMAIL")
+		}
+		if c.GitUsername == "" {
+			missing = append(missing, "GIT_USERNAME")
+		}
+	}
+
+	return missing
+}
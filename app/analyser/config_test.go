@@ -0,0 +1,341 @@
+package analyser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var configEnv = []string{
+	"ORGANISATION", "NAME", "REDIS_HOST", "REDIS_PORT", "REDIS_PASSWORD",
+	"GITHUB_TOKEN", "GIT_EMAIL", "GIT_USERNAME", "WEBHOOK_SECRET",
+	"GITHUB_APP_ID", "GITHUB_APP_PRIVATE_KEY", "GITHUB_APP_PRIVATE_KEY_PATH",
+	"GITHUB_API_URL", "GITHUB_UPLOAD_URL", "GIT_HOST", "COMMIT_METHOD",
+	"PULL_REQUEST_LABELS", "PULL_REQUEST_ASSIGNEES", "CHECK_RUNS",
+	"COMMIT_STATUSES", "FORKS", "TRACKING_ISSUES", "SKIP_PULL_REQUESTS",
+	"RELEASE_QUEUE_URL",
+}
+
+// Empties every setting so the tests don't pick up the developer's environment
+func clearConfigEnv(t *testing.T) {
+	for _, key := range configEnv {
+		t.Setenv(key, "")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("Loads every setting from the environment", func(t *testing.T) {
+		clearConfigEnv(t)
+		t.Setenv("ORGANISATION", "stylelia")
+		t.Setenv("NAME", "snort")
+		t.Setenv("REDIS_HOST", "redis")
+		t.Setenv("REDIS_PORT", "6379")
+		t.Setenv("REDIS_PASSWORD", "MySecurePassword")
+		t.Setenv("GITHUB_TOKEN", "token")
+		t.Setenv("GIT_EMAIL", "email@example.com")
+		t.Setenv("GIT_USERNAME", "My Name")
+		t.Setenv("WEBHOOK_SECRET", "secret")
+		t.Setenv("RELEASE_QUEUE_URL", "https://sqs.eu-west-1.amazonaws.com/123456789012/stylelia")
+
+		expected := Config{
+			Organisation:  "stylelia",
+			Name:          "snort",
+			RedisHost:     "redis",
+			RedisPort:     6379,
+			RedisPassword: "MySecurePassword",
+			GithubToken:   "token",
+			GitEmail:      "email@example.com",
+			GitUsername:   "My Name",
+			WebhookSecret: "secret",
+
+			ReleaseQueueURL: "https://sqs.eu-west-1.amazonaws.com/123456789012/stylelia",
+		}
+
+		config, err := LoadConfig("")
+		assert.NoError(t, err)
+		assert.Equal(t, expected, config)
+	})
+
+	t.Run("The file wins over the environment which fills in the gaps", func(t *testing.T) {
+		clearConfigEnv(t)
+		t.Setenv("REDIS_HOST", "envRedis")
+		t.Setenv("REDIS_PORT", "1234")
+		t.Setenv("GITHUB_TOKEN", "envToken")
+
+		path := filepath.Join(t.TempDir(), "stylelia.yml")
+		err := os.WriteFile(path, []byte("redis_host: fileRedis\nredis_port: 6379\ngit_email: email@example.com\n"), 0600)
+		assert.NoError(t, err)
+
+		config, err := LoadConfig(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "fileRedis", config.RedisHost)
+		assert.Equal(t, uint16(6379), config.RedisPort)
+		assert.Equal(t, "envToken", config.GithubToken)
+		assert.Equal(t, "email@example.com", config.GitEmail)
+	})
+
+	t.Run("An invalid port is an error", func(t *testing.T) {
+		clearConfigEnv(t)
+		t.Setenv("REDIS_PORT", "not a port")
+
+		_, err := LoadConfig("")
+		assert.EqualError(t, err, `config: REDIS_PORT "not a port" is not a valid port`)
+	})
+
+	t.Run("Loads the GitHub App from the environment", func(t *testing.T) {
+		clearConfigEnv(t)
+		t.Setenv("GITHUB_APP_ID", "1234")
+		t.Setenv("GITHUB_APP_PRIVATE_KEY_PATH", "/etc/stylelia/app.pem")
+
+		config, err := LoadConfig("")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1234), config.GithubAppID)
+		assert.Equal(t, "/etc/stylelia/app.pem", config.GithubAppPrivateKeyPath)
+	})
+
+	t.Run("An invalid app id is an error", func(t *testing.T) {
+		clearConfigEnv(t)
+		t.Setenv("GITHUB_APP_ID", "stylelia")
+
+		_, err := LoadConfig("")
+		assert.EqualError(t, err, `config: GITHUB_APP_ID "stylelia" is not a valid app id`)
+	})
+
+	t.Run("Loads enterprise servers from the file", func(t *testing.T) {
+		clearConfigEnv(t)
+		t.Setenv("GITHUB_API_URL", "https://github.example.com/api/v3/")
+
+		path := filepath.Join(t.TempDir(), "stylelia.yml")
+		content := "github_servers:\n  sous-chefs:\n    api_url: https://ghe.sous-chefs.org\n    token: ghToken\n"
+		err := os.WriteFile(path, []byte(content), 0600)
+		assert.NoError(t, err)
+
+		config, err := LoadConfig(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "https://github.example.com/api/v3/", config.GithubApiURL)
+		assert.Equal(t, GithubServer{ApiURL: "https://ghe.sous-chefs.org", Token: "ghToken"}, config.GithubServers["sous-chefs"])
+	})
+
+	t.Run("Splits the labels and assignees on commas", func(t *testing.T) {
+		clearConfigEnv(t)
+		t.Setenv("PULL_REQUEST_LABELS", "cookstyle, automated")
+		t.Setenv("PULL_REQUEST_ASSIGNEES", "xorima,,youshy ")
+
+		config, err := LoadConfig("")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"cookstyle", "automated"}, config.PullRequestLabels)
+		assert.Equal(t, []string{"xorima", "youshy"}, config.PullRequestAssignees)
+	})
+
+	t.Run("Turns on check runs, commit statuses, forks and tracking issues from the environment", func(t *testing.T) {
+		clearConfigEnv(t)
+		t.Setenv("SKIP_PULL_REQUESTS", "true")
+		t.Setenv("CHECK_RUNS", "true")
+		t.Setenv("COMMIT_STATUSES", "1")
+		t.Setenv("FORKS", "TRUE")
+		t.Setenv("TRACKING_ISSUES", "t")
+
+		config, err := LoadConfig("")
+		assert.NoError(t, err)
+		assert.True(t, config.SkipPullRequests)
+		assert.True(t, config.CheckRuns)
+		assert.True(t, config.CommitStatuses)
+		assert.True(t, config.Forks)
+		assert.True(t, config.TrackingIssues)
+	})
+
+	t.Run("An invalid check runs setting is an error", func(t *testing.T) {
+		clearConfigEnv(t)
+		t.Setenv("CHECK_RUNS", "sometimes")
+
+		_, err := LoadConfig("")
+		assert.EqualError(t, err, `config: CHECK_RUNS "sometimes" is not true or false`)
+	})
+
+	t.Run("A missing file is an error", func(t *testing.T) {
+		clearConfigEnv(t)
+
+		_, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yml"))
+		assert.Error(t, err)
+	})
+}
+
+func TestConfigGithubServer(t *testing.T) {
+	config := Config{
+		GithubApiURL: "https://github.example.com",
+		GithubServers: map[string]GithubServer{
+			"sous-chefs":       {ApiURL: "https://ghe.sous-chefs.org"},
+			"stylelia/private": {ApiURL: "https://ghe.stylelia.io"},
+		},
+	}
+
+	testCases := []struct {
+		desc     string
+		org      string
+		name     string
+		expected string
+	}{
+		{desc: "Falls back to the deployment's server", org: "stylelia", name: "snort", expected: "https://github.example.com"},
+		{desc: "Uses the organisation's server", org: "sous-chefs", name: "nginx", expected: "https://ghe.sous-chefs.org"},
+		{desc: "Uses the organisation's server for the whole organisation", org: "sous-chefs", expected: "https://ghe.sous-chefs.org"},
+		{desc: "Uses the repository's server over the organisation's", org: "stylelia", name: "private", expected: "https://ghe.stylelia.io"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			assert.Equal(t, tC.expected, config.githubServer(tC.org, tC.name).ApiURL)
+		})
+	}
+}
+
+func TestConfigAutoMergeMethod(t *testing.T) {
+	config := Config{AutoMerge: map[string]string{"sous-chefs": MergeWithSquash, "sous-chefs/java": MergeWithRebase}}
+
+	method, ok := config.autoMergeMethod("sous-chefs", "nginx")
+	assert.True(t, ok)
+	assert.Equal(t, MergeWithSquash, method)
+
+	method, ok = config.autoMergeMethod("sous-chefs", "java")
+	assert.True(t, ok)
+	assert.Equal(t, MergeWithRebase, method)
+
+	_, ok = config.autoMergeMethod("stylelia", "snort")
+	assert.False(t, ok)
+}
+
+func TestConfigRaisesPullRequests(t *testing.T) {
+	config := Config{SkipPullRequests: true, PullRequests: map[string]bool{"sous-chefs": true, "sous-chefs/java": false}}
+
+	assert.True(t, config.raisesPullRequests("sous-chefs", "nginx"))
+	assert.False(t, config.raisesPullRequests("sous-chefs", "java"))
+	assert.False(t, config.raisesPullRequests("stylelia", "snort"))
+	assert.True(t, Config{}.raisesPullRequests("stylelia", "snort"))
+}
+
+func TestConfigValidate(t *testing.T) {
+	complete := Config{
+		RedisHost:   "redis",
+		RedisPort:   6379,
+		GithubToken: "token",
+		GitEmail:    "email@example.com",
+		GitUsername: "My Name",
+	}
+
+	t.Run("A complete config is valid", func(t *testing.T) {
+		assert.NoError(t, complete.Validate())
+	})
+
+	t.Run("Every missing setting is listed at once", func(t *testing.T) {
+		config := Config{RedisHost: "redis"}
+		assert.EqualError(t, config.Validate(), "config: missing settings: REDIS_PORT, GITHUB_TOKEN, GIT_EMAIL, GIT_USERNAME")
+	})
+
+	t.Run("A GitHub App replaces the token but needs a private key", func(t *testing.T) {
+		config := complete
+		config.GithubToken = ""
+		config.GithubAppID = 1234
+		assert.EqualError(t, config.Validate(), "config: missing settings: GITHUB_APP_PRIVATE_KEY")
+
+		config.GithubAppPrivateKeyPath = "/etc/stylelia/app.pem"
+		assert.NoError(t, config.Validate())
+	})
+
+	t.Run("Enterprise server URLs must be absolute", func(t *testing.T) {
+		config := complete
+		config.GithubApiURL = "github.example.com"
+		assert.EqualError(t, config.Validate(), `config: "github.example.com" is not an absolute URL`)
+
+		config = complete
+		config.GithubServers = map[string]GithubServer{"sous-chefs": {ApiURL: "ghe"}}
+		assert.EqualError(t, config.Validate(), `config: github_servers: sous-chefs: "ghe" is not an absolute URL`)
+	})
+
+	t.Run("Committing through the API doesn't need a git identity", func(t *testing.T) {
+		config := complete
+		config.GitEmail = ""
+		config.GitUsername = ""
+		config.CommitMethod = CommitWithAPI
+		assert.NoError(t, config.Validate())
+
+		// GitLab and Gitea are still pushed to with git
+		config.GitlabServers = map[string]GitlabServer{"stylelia": {Token: "glToken"}}
+		assert.EqualError(t, config.Validate(), "config: missing settings: GIT_EMAIL, GIT_USERNAME")
+	})
+
+	t.Run("Only known commit methods are valid", func(t *testing.T) {
+		config := complete
+		config.CommitMethod = "ftp"
+		assert.EqualError(t, config.Validate(), `config: COMMIT_METHOD "ftp" is not one of git or api`)
+	})
+
+	t.Run("Only known merge methods are valid", func(t *testing.T) {
+		config := complete
+		config.AutoMerge = map[string]string{"stylelia/snort": "octopus"}
+		assert.EqualError(t, config.Validate(), `config: auto_merge: stylelia/snort: "octopus" is not one of merge, squash or rebase`)
+	})
+
+	t.Run("Check runs need a GitHub App", func(t *testing.T) {
+		config := complete
+		config.CheckRuns = true
+		assert.EqualError(t, config.Validate(), "config: CHECK_RUNS needs Stylelia running as a GitHub App, GITHUB_APP_ID is missing")
+
+		config.GithubAppID = 1234
+		config.GithubAppPrivateKey = "key"
+		assert.NoError(t, config.Validate())
+	})
+
+	t.Run("GitHub servers other than the deployment's need a token", func(t *testing.T) {
+		config := complete
+		config.GithubServers = map[string]GithubServer{"stylelia/snort": {ApiURL: "https://ghe.stylelia.io/api/v3/"}}
+		assert.EqualError(t, config.Validate(), "config: github_servers: stylelia/snort: missing token for https://ghe.stylelia.io/api/v3/")
+
+		config.GithubServers = map[string]GithubServer{"stylelia/snort": {ApiURL: "https://ghe.stylelia.io/api/v3/", Token: "gheToken"}}
+		assert.NoError(t, config.Validate())
+
+		// Only the git host differs, the deployment's token still works
+		config.GithubServers = map[string]GithubServer{"stylelia/snort": {GitHost: "git.stylelia.io"}}
+		assert.NoError(t, config.Validate())
+
+		config.GithubApiURL = "https://github.example.com/api/v3"
+		assert.EqualError(t, config.Validate(), "config: github_servers: stylelia/snort: missing token for https://api.github.com/")
+	})
+
+	t.Run("GitLab servers need a token", func(t *testing.T) {
+		config := complete
+		config.GitlabServers = map[string]GitlabServer{"chef": {}}
+		assert.EqualError(t, config.Validate(), "config: gitlab_servers: chef: missing token for https://gitlab.com")
+	})
+
+	t.Run("GitLab and Gitea alone don't need GitHub credentials", func(t *testing.T) {
+		config := complete
+		config.GithubToken = ""
+		config.GitlabServers = map[string]GitlabServer{"chef": {Token: "glToken"}}
+		config.GiteaServers = map[string]GiteaServer{"stylelia/snort": {URL: "https://gitea.example.com", Token: "gtToken"}}
+		assert.NoError(t, config.Validate())
+
+		// The default repository is on GitHub
+		config.Organisation = "sous-chefs"
+		config.Name = "java"
+		assert.EqualError(t, config.Validate(), "config: missing settings: GITHUB_TOKEN")
+
+		config.Organisation = "chef"
+		assert.NoError(t, config.Validate())
+
+		config.GithubServers = map[string]GithubServer{"sous-chefs": {GitHost: "git.sous-chefs.io"}}
+		assert.EqualError(t, config.Validate(), "config: missing settings: GITHUB_TOKEN")
+
+		// Webhooks only come from GitHub
+		config.GithubServers = nil
+		config.WebhookSecret = "secret"
+		assert.EqualError(t, config.ValidateServer(), "config: missing settings: GITHUB_TOKEN")
+	})
+
+	t.Run("The server also needs a webhook secret", func(t *testing.T) {
+		assert.EqualError(t, complete.ValidateServer(), "config: missing settings: WEBHOOK_SECRET")
+
+		config := complete
+		config.WebhookSecret = "secret"
+		assert.NoError(t, config.ValidateServer())
+	})
+}
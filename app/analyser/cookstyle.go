@@ -32,11 +32,20 @@ type Files struct {
 }
 
 type Offenses struct {
-	Severity    string `json:"severity"`
-	Message     string `json:"message"`
-	CopName     string `json:"cop_name"`
-	Corrected   bool   `json:"corrected"`
-	Correctable bool   `json:"correctable"`
+	Severity    string   `json:"severity"`
+	Message     string   `json:"message"`
+	CopName     string   `json:"cop_name"`
+	Corrected   bool     `json:"corrected"`
+	Correctable bool     `json:"correctable"`
+	Location    Location `json:"location"`
+}
+
+// Where an offense is in its file, lines and columns count from 1
+type Location struct {
+	StartLine   int `json:"start_line"`
+	StartColumn int `json:"start_column"`
+	LastLine    int `json:"last_line"`
+	LastColumn  int `json:"last_column"`
 }
 
 type Summary struct {
@@ -50,7 +59,8 @@ func runCookstyle(exec CommandRunner) (CookstyleCheck, error) {
 
 	// cmd := exec.Command("cookstyle", "-a", "--format", "json")
 	output, err := exec.Output()
-	if err != nil {
+	// Cookstyle exits with 1 when it leaves offences uncorrected, the results are still there
+	if err != nil && exitCode(err) != 1 {
 		return c, err
 	}
 
@@ -0,0 +1,66 @@
+package analyser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// What the analyser would have done to a repository, reported instead of
+// pushing, raising a PR and updating the cache when running dry
+type DryRun struct {
+	Name          string `json:"name"`
+	DefaultBranch string `json:"default_branch"`
+	LatestCommit  string `json:"latest_commit"`
+	Tool          string `json:"tool"`
+	ToolVersion   string `json:"tool_version"`
+	OffenseCount  int    `json:"offense_count"`
+	Branch        string `json:"branch"`
+	Title         string `json:"title"`
+	Body          string `json:"body"`
+	Diff          string `json:"diff"`
+	// The files Cookstyle corrected, there's no PR to raise without any
+	Paths []string `json:"paths"`
+	// Whether a PR would be raised, false when Pull Requests are turned off
+	PullRequest bool `json:"pull_request"`
+	// Whether the results would be published as a check run
+	CheckRun bool `json:"check_run"`
+}
+
+func NewDryRun(repo Repository, tool, toolVersion, branch, title, body string, check CookstyleCheck, pullRequest bool) DryRun {
+	return DryRun{
+		Name:          repo.FullName(),
+		DefaultBranch: repo.DefaultBranch,
+		LatestCommit:  repo.LatestCommit,
+		Tool:          tool,
+		ToolVersion:   toolVersion,
+		OffenseCount:  check.Summary.OffenseCount,
+		Branch:        branch,
+		Title:         title,
+		Body:          body,
+		PullRequest:   pullRequest,
+	}
+}
+
+// Human readable version of the dry run
+func (d DryRun) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Dry run of %s at %s (%s %s, %d offences)\n", d.Name, d.LatestCommit, d.Tool, d.ToolVersion, d.OffenseCount)
+	if d.CheckRun {
+		fmt.Fprintf(&b, "Would publish the %q check run on %s\n", checkRunName, d.LatestCommit)
+	}
+	if d.OffenseCount == 0 {
+		b.WriteString("Nothing to change\n")
+		return b.String()
+	}
+	if !d.PullRequest {
+		b.WriteString("Pull Requests are turned off, would not push or raise one\n")
+		return b.String()
+	}
+	if len(d.Paths) == 0 {
+		b.WriteString("Cookstyle corrected nothing, would not raise a PR\n")
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "Would push %s and raise %q against %s with body:\n\n%s\n\nDiff:\n%s", d.Branch, d.Title, d.DefaultBranch, d.Body, d.Diff)
+	return b.String()
+}
@@ -0,0 +1,93 @@
+package analyser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDryRun(t *testing.T) {
+	repo := NewRepo("stylelia", "snort", "main")
+	repo.LatestCommit = "abc123"
+
+	dryRun := NewDryRun(repo, Cookstyle, "7.25.6", "stylelia/cookstyle_7.25.6", "Title", "Body", cookstyleJSON, true)
+
+	expected := DryRun{
+		Name:          "stylelia/snort",
+		DefaultBranch: "main",
+		LatestCommit:  "abc123",
+		Tool:          Cookstyle,
+		ToolVersion:   "7.25.6",
+		OffenseCount:  2,
+		Branch:        "stylelia/cookstyle_7.25.6",
+		Title:         "Title",
+		Body:          "Body",
+		PullRequest:   true,
+	}
+	assert.Equal(t, expected, dryRun)
+}
+
+func TestDryRunSummary(t *testing.T) {
+	t.Run("A clean repository has nothing to change", func(t *testing.T) {
+		dryRun := DryRun{Name: "snort", LatestCommit: "abc123", Tool: Cookstyle, ToolVersion: "7.25.6"}
+
+		expected := "Dry run of snort at abc123 (Cookstyle 7.25.6, 0 offences)\nNothing to change\n"
+		assert.Equal(t, expected, dryRun.Summary())
+	})
+
+	t.Run("Offences show the PR and diff", func(t *testing.T) {
+		dryRun := DryRun{
+			Name:          "snort",
+			DefaultBranch: "main",
+			LatestCommit:  "abc123",
+			Tool:          Cookstyle,
+			ToolVersion:   "7.25.6",
+			OffenseCount:  1,
+			Branch:        "stylelia/cookstyle_7.25.6",
+			Title:         "Title",
+			Body:          "Body",
+			Diff:          "+fixed\n",
+			Paths:         []string{"metadata.rb"},
+			PullRequest:   true,
+		}
+
+		expected := "Dry run of snort at abc123 (Cookstyle 7.25.6, 1 offences)\nWould push stylelia/cookstyle_7.25.6 and raise \"Title\" against main with body:\n\nBody\n\nDiff:\n+fixed\n"
+		assert.Equal(t, expected, dryRun.Summary())
+	})
+
+	t.Run("No PR when Cookstyle corrected nothing", func(t *testing.T) {
+		dryRun := DryRun{
+			Name:          "snort",
+			DefaultBranch: "main",
+			LatestCommit:  "abc123",
+			Tool:          Cookstyle,
+			ToolVersion:   "7.25.6",
+			OffenseCount:  1,
+			Branch:        "stylelia/cookstyle_7.25.6",
+			Title:         "Title",
+			Body:          "Body",
+			PullRequest:   true,
+		}
+
+		expected := "Dry run of snort at abc123 (Cookstyle 7.25.6, 1 offences)\nCookstyle corrected nothing, would not raise a PR\n"
+		assert.Equal(t, expected, dryRun.Summary())
+	})
+
+	t.Run("Only the check run is reported when Pull Requests are turned off", func(t *testing.T) {
+		dryRun := DryRun{
+			Name:          "snort",
+			DefaultBranch: "main",
+			LatestCommit:  "abc123",
+			Tool:          Cookstyle,
+			ToolVersion:   "7.25.6",
+			OffenseCount:  1,
+			Branch:        "stylelia/cookstyle_7.25.6",
+			Title:         "Title",
+			Body:          "Body",
+			CheckRun:      true,
+		}
+
+		expected := "Dry run of snort at abc123 (Cookstyle 7.25.6, 1 offences)\nWould publish the \"Stylelia Cookstyle\" check run on abc123\nPull Requests are turned off, would not push or raise one\n"
+		assert.Equal(t, expected, dryRun.Summary())
+	})
+}
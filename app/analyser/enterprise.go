@@ -0,0 +1,74 @@
+package analyser
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/v39/github"
+)
+
+const githubHost string = "github.com"
+
+// A GitHub instance, github.com when ApiURL is empty
+type GithubServer struct {
+	// API base URL, e.g. https://github.example.com/api/v3/
+	ApiURL string `yaml:"api_url"`
+	// Defaults to the uploads endpoint on the same host as ApiURL
+	UploadURL string `yaml:"upload_url"`
+	// Host repositories are cloned from and pushed to, defaults to the host of ApiURL
+	GitHost string `yaml:"git_host"`
+	// Token for this server, only optional when it's the deployment's own
+	// server, where the deployment's GitHub token or app is used
+	Token string `yaml:"token"`
+}
+
+// Builds a client for the server, using go-github's enterprise client for anything but github.com
+func (s GithubServer) client(httpClient *http.Client) (*github.Client, error) {
+	if s.ApiURL == "" {
+		return github.NewClient(httpClient), nil
+	}
+
+	uploadURL := s.UploadURL
+	if uploadURL == "" {
+		// The enterprise client adds api/uploads/ to the bare host
+		uploadURL = strings.TrimSuffix(strings.TrimSuffix(s.ApiURL, "/"), "/api/v3")
+	}
+
+	return github.NewEnterpriseClient(s.ApiURL, uploadURL, httpClient)
+}
+
+// Returns the host git talks to
+func (s GithubServer) gitHost() string {
+	if s.GitHost != "" {
+		return s.GitHost
+	}
+	if s.ApiURL == "" {
+		return githubHost
+	}
+
+	// Already checked by validate
+	apiURL, _ := url.Parse(s.ApiURL)
+	// Servers with an API subdomain serve git from the parent domain
+	return strings.TrimPrefix(apiURL.Host, "api.")
+}
+
+func (s GithubServer) validate() error {
+	for _, raw := range []string{s.ApiURL, s.UploadURL} {
+		if raw == "" {
+			continue
+		}
+
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%q is not an absolute URL", raw)
+		}
+	}
+
+	if s.UploadURL != "" && s.ApiURL == "" {
+		return fmt.Errorf("upload URL %q set without an API URL", s.UploadURL)
+	}
+
+	return nil
+}
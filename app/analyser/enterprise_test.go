@@ -0,0 +1,103 @@
+package analyser
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/youshy/logger"
+)
+
+func TestGithubServerClient(t *testing.T) {
+	t.Run("Talks to github.com by default", func(t *testing.T) {
+		client, err := GithubServer{}.client(&http.Client{})
+		assert.NoError(t, err)
+		assert.Equal(t, "https://api.github.com/", client.BaseURL.String())
+		assert.Equal(t, "https://uploads.github.com/", client.UploadURL.String())
+	})
+
+	t.Run("Talks to the API and uploads endpoints of an enterprise server", func(t *testing.T) {
+		client, err := GithubServer{ApiURL: "https://github.example.com/api/v3/"}.client(&http.Client{})
+		assert.NoError(t, err)
+		assert.Equal(t, "https://github.example.com/api/v3/", client.BaseURL.String())
+		assert.Equal(t, "https://github.example.com/api/uploads/", client.UploadURL.String())
+	})
+
+	t.Run("Uses the upload URL when one is given", func(t *testing.T) {
+		server := GithubServer{ApiURL: "https://github.example.com", UploadURL: "https://uploads.example.com/api/uploads/"}
+		client, err := server.client(&http.Client{})
+		assert.NoError(t, err)
+		assert.Equal(t, "https://github.example.com/api/v3/", client.BaseURL.String())
+		assert.Equal(t, "https://uploads.example.com/api/uploads/", client.UploadURL.String())
+	})
+}
+
+func TestGithubServerGitHost(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		server   GithubServer
+		expected string
+	}{
+		{desc: "Defaults to github.com", server: GithubServer{}, expected: "github.com"},
+		{desc: "Takes the host of the API URL", server: GithubServer{ApiURL: "https://github.example.com/api/v3/"}, expected: "github.example.com"},
+		{desc: "Drops the API subdomain", server: GithubServer{ApiURL: "https://api.octo.ghe.com"}, expected: "octo.ghe.com"},
+		{desc: "Prefers the configured git host", server: GithubServer{ApiURL: "https://github.example.com", GitHost: "git.example.com"}, expected: "git.example.com"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			assert.Equal(t, tC.expected, tC.server.gitHost())
+		})
+	}
+}
+
+func TestGithubServerValidate(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		server   GithubServer
+		expected string
+	}{
+		{desc: "Accepts github.com", server: GithubServer{}},
+		{desc: "Accepts an enterprise server", server: GithubServer{ApiURL: "https://github.example.com/api/v3/"}},
+		{desc: "Rejects a relative API URL", server: GithubServer{ApiURL: "github.example.com"}, expected: `"github.example.com" is not an absolute URL`},
+		{desc: "Rejects an upload URL without an API URL", server: GithubServer{UploadURL: "https://github.example.com"}, expected: `upload URL "https://github.example.com" set without an API URL`},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			err := tC.server.validate()
+			if tC.expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tC.expected)
+			}
+		})
+	}
+}
+
+func TestHandlerGithubClientEnterprise(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v3/repos/stylelia/snort", r.URL.Path)
+		assert.Equal(t, "Bearer enterpriseToken", r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"default_branch": "main"}`)
+	}))
+	defer server.Close()
+
+	handler := Handler{
+		Log:    logger.NewLogger(logger.DEBUG, false),
+		Tokens: staticToken("privateToken"),
+		Config: Config{
+			GithubServers: map[string]GithubServer{
+				"stylelia/snort": {ApiURL: server.URL, Token: "enterpriseToken"},
+			},
+		},
+	}
+
+	client, err := handler.githubClient(context.Background(), "stylelia", "snort")
+	assert.NoError(t, err)
+
+	branch, err := getDefaultBranch(context.Background(), client, "stylelia", "snort")
+	assert.NoError(t, err)
+	assert.Equal(t, "main", branch)
+}
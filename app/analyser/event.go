@@ -0,0 +1,90 @@
+package analyser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Lambda event payload
+// An empty organisation or name falls back to the config so a bare '{}' event
+// behaves the same as the original env driven invocation
+type Event struct {
+	Organisation string   `json:"organisation" yaml:"organisation"`
+	Name         string   `json:"name" yaml:"name"`
+	Tools        []string `json:"tools" yaml:"tools"`
+	Force        bool     `json:"force" yaml:"force"`
+	DryRun       bool     `json:"dry_run" yaml:"dry_run"`
+	// Runs against every cookbook in the organisation rather than just Name
+	Scan bool `json:"scan" yaml:"scan"`
+	// File at the root of a repository that marks it as a cookbook, used when scanning
+	Marker string `json:"marker" yaml:"marker"`
+	// Runs every repository in the cache if a new tool version has been released
+	Release bool `json:"release" yaml:"release"`
+	// Number of repositories processed at the same time
+	Concurrency int `json:"concurrency" yaml:"concurrency"`
+}
+
+// Tools we know how to run against a repository
+var supportedTools = []string{Cookstyle}
+
+// Fills in the empty fields of the event from the config and defaults
+func (e Event) withDefaults(config Config) Event {
+	if e.Organisation == "" {
+		e.Organisation = config.Organisation
+	}
+	if e.Name == "" {
+		e.Name = config.Name
+	}
+	if len(e.Tools) == 0 {
+		e.Tools = supportedTools
+	}
+	if e.Marker == "" {
+		e.Marker = defaultCookbookMarker
+	}
+	if e.Concurrency == 0 {
+		e.Concurrency = defaultConcurrency
+	}
+
+	return e
+}
+
+func (e Event) validate() error {
+	// A release runs every repository in the cache, whatever their organisation
+	if e.Organisation == "" && !e.Release {
+		return errors.New("event: organisation not set")
+	}
+	if e.Name == "" && !e.Scan && !e.Release {
+		return errors.New("event: repository name not set")
+	}
+	if e.Concurrency < 0 {
+		return fmt.Errorf("event: concurrency must be positive, got %d", e.Concurrency)
+	}
+	for _, tool := range e.Tools {
+		if !isSupportedTool(tool) {
+			return fmt.Errorf("event: unsupported tool %q", tool)
+		}
+	}
+
+	return nil
+}
+
+func (e Event) runsTool(tool string) bool {
+	for _, t := range e.Tools {
+		if strings.EqualFold(t, tool) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isSupportedTool(tool string) bool {
+	for _, t := range supportedTools {
+		if strings.EqualFold(t, tool) {
+			return true
+		}
+	}
+
+	return false
+}
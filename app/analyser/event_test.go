@@ -0,0 +1,76 @@
+package analyser
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventUnmarshal(t *testing.T) {
+	payload := `{"organisation": "stylelia", "name": "snort", "tools": ["cookstyle"], "force": true, "dry_run": true}`
+	expected := Event{
+		Organisation: "stylelia",
+		Name:         "snort",
+		Tools:        []string{"cookstyle"},
+		Force:        true,
+		DryRun:       true,
+	}
+
+	var actual Event
+	err := json.Unmarshal([]byte(payload), &actual)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func TestEventWithDefaults(t *testing.T) {
+	config := Config{Organisation: "configOrg", Name: "configName"}
+
+	t.Run("Empty fields are taken from the config", func(t *testing.T) {
+		event := Event{}.withDefaults(config)
+		assert.Equal(t, "configOrg", event.Organisation)
+		assert.Equal(t, "configName", event.Name)
+		assert.Equal(t, []string{Cookstyle}, event.Tools)
+		assert.Equal(t, "metadata.rb", event.Marker)
+	})
+
+	t.Run("Fields set on the event win over the config", func(t *testing.T) {
+		event := Event{Organisation: "eventOrg", Name: "eventName", Tools: []string{"cookstyle"}}.withDefaults(config)
+		assert.Equal(t, "eventOrg", event.Organisation)
+		assert.Equal(t, "eventName", event.Name)
+		assert.Equal(t, []string{"cookstyle"}, event.Tools)
+	})
+}
+
+func TestEventValidate(t *testing.T) {
+	t.Run("A complete event is valid", func(t *testing.T) {
+		event := Event{Organisation: "org", Name: "name", Tools: []string{Cookstyle}}
+		assert.NoError(t, event.validate())
+	})
+
+	t.Run("Missing organisation is an error", func(t *testing.T) {
+		event := Event{Name: "name", Tools: []string{Cookstyle}}
+		assert.Error(t, event.validate())
+	})
+
+	t.Run("Missing name is an error", func(t *testing.T) {
+		event := Event{Organisation: "org", Tools: []string{Cookstyle}}
+		assert.Error(t, event.validate())
+	})
+
+	t.Run("Missing name is fine when scanning", func(t *testing.T) {
+		event := Event{Organisation: "org", Tools: []string{Cookstyle}, Scan: true}
+		assert.NoError(t, event.validate())
+	})
+
+	t.Run("Unsupported tool is an error", func(t *testing.T) {
+		event := Event{Organisation: "org", Name: "name", Tools: []string{"chefstyle"}}
+		assert.EqualError(t, event.validate(), `event: unsupported tool "chefstyle"`)
+	})
+}
+
+func TestEventRunsTool(t *testing.T) {
+	event := Event{Tools: []string{"cookstyle"}}
+	assert.True(t, event.runsTool(Cookstyle))
+	assert.False(t, event.runsTool("chefstyle"))
+}
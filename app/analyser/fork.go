@@ -0,0 +1,116 @@
+package analyser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v39/github"
+)
+
+// GitHub creates forks in the background, how long to wait for one to be usable
+const (
+	forkReadyAttempts int           = 10
+	forkReadyDelay    time.Duration = 3 * time.Second
+)
+
+// Providers that can push to a fork when the bot can't push to the repository
+type forker interface {
+	// Returns the bot's fork of repo, nil when the bot can push to repo itself.
+	// With create a missing fork is created and an existing one has its
+	// default branch brought up to repo.LatestCommit, without it the fork
+	// is only looked up, or named as it would be once created.
+	Fork(ctx context.Context, repo Repository, create bool) (*Repository, error)
+}
+
+// Returns the fork to push changes for repo to, nil when pushing to repo itself
+func forkFor(ctx context.Context, provider Provider, repo Repository, create bool) (*Repository, error) {
+	f, ok := provider.(forker)
+	if !ok {
+		return nil, fmt.Errorf("forks are only supported on GitHub")
+	}
+
+	return f.Fork(ctx, repo, create)
+}
+
+// The repository changes are pushed to and Pull Requests are opened from,
+// the fork when there is one. It starts from the same commit as repo.
+func (r Repository) head() Repository {
+	if r.Fork == nil {
+		return r
+	}
+
+	head := *r.Fork
+	head.DefaultBranch = r.DefaultBranch
+	head.LatestCommit = r.LatestCommit
+	return head
+}
+
+func (g *githubProvider) Fork(ctx context.Context, repo Repository, create bool) (*Repository, error) {
+	upstream, _, err := g.client.Repositories.Get(ctx, repo.Org, repo.Name)
+	if err != nil {
+		return nil, githubError(err)
+	}
+	// Installation tokens aren't given permissions, apps can't own forks anyway
+	if upstream.Permissions == nil || upstream.Permissions["push"] {
+		return nil, nil
+	}
+
+	user, _, err := g.client.Users.Get(ctx, "")
+	if err != nil {
+		return nil, githubError(err)
+	}
+	fork := NewRepo(user.GetLogin(), repo.Name, repo.DefaultBranch)
+
+	existing, _, err := g.client.Repositories.Get(ctx, fork.Org, fork.Name)
+	err = githubError(err)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	forked := err == nil && strings.EqualFold(existing.GetParent().GetFullName(), repo.FullName())
+
+	if !create {
+		return &fork, nil
+	}
+
+	if forked {
+		// The fork is only ever pushed to by the bot, so nothing is lost by forcing it
+		ref := &github.Reference{
+			Ref:    github.String("refs/heads/" + repo.DefaultBranch),
+			Object: &github.GitObject{SHA: github.String(repo.LatestCommit)},
+		}
+		_, _, err = g.client.Git.UpdateRef(ctx, fork.Org, fork.Name, ref, true)
+		if err != nil {
+			return nil, githubError(err)
+		}
+		return &fork, nil
+	}
+
+	created, _, err := g.client.Repositories.CreateFork(ctx, repo.Org, repo.Name, nil)
+	var accepted *github.AcceptedError
+	if err != nil && !errors.As(err, &accepted) {
+		return nil, githubError(err)
+	}
+	// Taken when the bot already has a repository with the same name
+	fork.Name = created.GetName()
+
+	return &fork, g.waitForFork(ctx, fork)
+}
+
+// Waits for the default branch of a new fork to show up
+func (g *githubProvider) waitForFork(ctx context.Context, fork Repository) error {
+	for attempt := 1; ; attempt++ {
+		_, _, err := g.client.Git.GetRef(ctx, fork.Org, fork.Name, "refs/heads/"+fork.DefaultBranch)
+		err = githubError(err)
+		if err == nil || !errors.Is(err, ErrNotFound) || attempt == forkReadyAttempts {
+			return err
+		}
+
+		err = sleepContext(ctx, forkReadyDelay)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package analyser
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Fakes upstream sous-chefs/java, which the bot stylelia-bot can only read
+// unless canPush, and the bot's fork of it if forked
+func newFakeForks(t *testing.T, canPush, forked bool) (*httptest.Server, *[]string) {
+	var writes []string
+	record := func(r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		writes = append(writes, strings.TrimSpace(fmt.Sprintf("%s %s %s", r.Method, r.URL.Path, body)))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/sous-chefs/java", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"full_name": "sous-chefs/java", "permissions": {"pull": true, "push": %t}}`, canPush)
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"login": "stylelia-bot"}`)
+	})
+	mux.HandleFunc("/repos/stylelia-bot/java", func(w http.ResponseWriter, r *http.Request) {
+		if !forked {
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"full_name": "stylelia-bot/java", "fork": true, "parent": {"full_name": "sous-chefs/java"}}`)
+	})
+	mux.HandleFunc("/repos/stylelia-bot/java/git/refs/heads/main", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		fmt.Fprint(w, `{"ref": "refs/heads/main"}`)
+	})
+	mux.HandleFunc("/repos/sous-chefs/java/forks", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, `{"name": "java", "full_name": "stylelia-bot/java"}`)
+	})
+	mux.HandleFunc("/repos/stylelia-bot/java/git/ref/heads/main", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ref": "refs/heads/main"}`)
+	})
+
+	return httptest.NewServer(mux), &writes
+}
+
+func TestGithubProviderFork(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRepo("sous-chefs", "java", "main")
+	repo.LatestCommit = "b64d5bae3cee6da8c305c0f46f678914cb22e483"
+	fork := NewRepo("stylelia-bot", "java", "main")
+
+	t.Run("Doesn't fork a repository the bot can push to", func(t *testing.T) {
+		server, writes := newFakeForks(t, true, false)
+		defer server.Close()
+		github := &githubProvider{client: newTestGithubClient(server)}
+
+		actual, err := forkFor(ctx, github, repo, true)
+		assert.NoError(t, err)
+		assert.Nil(t, actual)
+		assert.Empty(t, *writes)
+	})
+
+	t.Run("Names the fork without creating it", func(t *testing.T) {
+		server, writes := newFakeForks(t, false, false)
+		defer server.Close()
+		github := &githubProvider{client: newTestGithubClient(server)}
+
+		actual, err := forkFor(ctx, github, repo, false)
+		assert.NoError(t, err)
+		assert.Equal(t, &fork, actual)
+		assert.Empty(t, *writes)
+	})
+
+	t.Run("Creates a missing fork", func(t *testing.T) {
+		server, writes := newFakeForks(t, false, false)
+		defer server.Close()
+		github := &githubProvider{client: newTestGithubClient(server)}
+
+		actual, err := forkFor(ctx, github, repo, true)
+		assert.NoError(t, err)
+		assert.Equal(t, &fork, actual)
+		assert.Equal(t, []string{"POST /repos/sous-chefs/java/forks"}, *writes)
+	})
+
+	t.Run("Syncs the default branch of an existing fork", func(t *testing.T) {
+		server, writes := newFakeForks(t, false, true)
+		defer server.Close()
+		github := &githubProvider{client: newTestGithubClient(server)}
+
+		actual, err := forkFor(ctx, github, repo, true)
+		assert.NoError(t, err)
+		assert.Equal(t, &fork, actual)
+		expected := []string{`PATCH /repos/stylelia-bot/java/git/refs/heads/main {"sha":"b64d5bae3cee6da8c305c0f46f678914cb22e483","force":true}`}
+		assert.Equal(t, expected, *writes)
+	})
+
+	t.Run("Opens the Pull Request from the fork", func(t *testing.T) {
+		var created string
+		mux := http.NewServeMux()
+		mux.HandleFunc("/repos/sous-chefs/java/pulls", func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			assert.NoError(t, err)
+			created = string(body)
+			fmt.Fprint(w, `{"number": 4, "html_url": "https://github.com/sous-chefs/java/pull/4"}`)
+		})
+		mux.HandleFunc("/repos/sous-chefs/java/pulls/4/requested_reviewers", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"users": [], "teams": []}`)
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		github := &githubProvider{client: newTestGithubClient(server)}
+
+		forked := repo
+		forked.Fork = &fork
+		_, err := github.OpenChangeRequest(ctx, forked, "stylelia/cookstyle_7.25.0", ChangeRequestDetails{Title: "Title", Body: "Body"})
+		assert.NoError(t, err)
+		assert.Contains(t, created, `"head":"stylelia-bot:stylelia/cookstyle_7.25.0"`)
+		assert.Contains(t, created, `"maintainer_can_modify":true`)
+	})
+
+	t.Run("Is only supported on GitHub", func(t *testing.T) {
+		gitlab := newGitlabProvider(&http.Client{}, GitlabServer{Token: "glToken"})
+
+		_, err := forkFor(ctx, gitlab, repo, false)
+		assert.EqualError(t, err, "forks are only supported on GitHub")
+	})
+}
+
+func TestRepositoryHead(t *testing.T) {
+	repo := NewRepo("sous-chefs", "java", "main")
+	repo.LatestCommit = "b64d5bae3cee6da8c305c0f46f678914cb22e483"
+	assert.Equal(t, repo, repo.head())
+
+	fork := NewRepo("stylelia-bot", "java-1", "")
+	repo.Fork = &fork
+	expected := Repository{Org: "stylelia-bot", Name: "java-1", DefaultBranch: "main", LatestCommit: "b64d5bae3cee6da8c305c0f46f678914cb22e483"}
+	assert.Equal(t, expected, repo.head())
+}
@@ -3,15 +3,22 @@ package analyser
 import (
 	"context"
 	"fmt"
-	"os"
 	"os/exec"
 
 	"github.com/google/go-github/v39/github"
 	"golang.org/x/oauth2"
 )
 
+// Works for both personal access tokens and GitHub App installation tokens
+func buildCloneURL(host, token string, repo Repository) string {
+	return fmt.Sprintf("https://x-access-token:%s@%s/%s/%s.git", token, host, repo.Org, repo.Name)
+}
+
+// Every Cookstyle branch starts with this, followed by the version
+const cookstyleBranchPrefix string = "stylelia/cookstyle_"
+
 func createBranchName(cookstyleVersion string) string {
-	return fmt.Sprintf("stylelia/cookstyle_%s", cookstyleVersion)
+	return cookstyleBranchPrefix + cookstyleVersion
 }
 
 func buildBranchCommand(branchName string) *exec.Cmd {
@@ -30,8 +37,23 @@ func buildCommitCommand(userEmail, userName, commitTitle, commitBody string) *ex
 	return exec.Command("git", "-c", commitUserEmail, "-c", commitUserName, "commit", "-s", "-m", commit)
 }
 
-func buildPushCommand(branchName string) *exec.Cmd {
-	return exec.Command("git", "push", "-u", "origin", branchName, "-f")
+// remote is origin, or the URL of a fork
+func buildPushCommand(remote, branchName string) *exec.Cmd {
+	return exec.Command("git", "push", "-u", remote, branchName, "-f")
+}
+
+// Shows the staged changes, stage first so new files show up as well
+func buildDiffCommand() *exec.Cmd {
+	return exec.Command("git", "diff", "--cached")
+}
+
+func gitDiff(exec CommandRunner) (string, error) {
+	output, err := exec.Output()
+	if err != nil {
+		return "", err
+	}
+
+	return string(output), nil
 }
 
 func gitCmdRunner(exec CommandRunner) error {
@@ -44,10 +66,7 @@ func gitCmdRunner(exec CommandRunner) error {
 	return nil
 }
 
-func createClientWithAuth(ctx context.Context) *github.Client {
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: os.Getenv("GITHUB_TOKEN")},
-	)
+func createClientWithAuth(ctx context.Context, ts oauth2.TokenSource) *github.Client {
 	tc := oauth2.NewClient(ctx, ts)
 
 	return github.NewClient(tc)
@@ -0,0 +1,388 @@
+package analyser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Gitea's largest page size by default
+const giteaPageSize int = 50
+
+// A self hosted Gitea or Forgejo instance, which share an API
+type GiteaServer struct {
+	URL string `yaml:"url"`
+	// Access token with write access to repositories
+	Token string `yaml:"token"`
+}
+
+func (s GiteaServer) url() string {
+	return strings.TrimSuffix(s.URL, "/")
+}
+
+func (s GiteaServer) validate() error {
+	if s.Token == "" {
+		return fmt.Errorf("missing token for %s", s.URL)
+	}
+
+	u, err := url.Parse(s.URL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%q is not an absolute URL", s.URL)
+	}
+
+	return nil
+}
+
+// Repositories on Gitea or Forgejo, talking to the v1 API directly
+type giteaProvider struct {
+	api    restAPI
+	server GiteaServer
+}
+
+func newGiteaProvider(client *http.Client, server GiteaServer) *giteaProvider {
+	api := restAPI{
+		name:       "gitea",
+		client:     client,
+		baseURL:    server.url() + "/api/v1",
+		authHeader: "Authorization",
+		authValue:  "token " + server.Token,
+	}
+
+	return &giteaProvider{api: api, server: server}
+}
+
+type giteaPullRequest struct {
+	Number             int          `json:"number"`
+	HTMLURL            string       `json:"html_url"`
+	Body               string       `json:"body"`
+	State              string       `json:"state"`
+	Merged             bool         `json:"merged"`
+	Head               giteaBranch  `json:"head"`
+	Base               giteaBranch  `json:"base"`
+	User               giteaUser    `json:"user"`
+	Assignees          []giteaUser  `json:"assignees"`
+	Labels             []giteaLabel `json:"labels"`
+	RequestedReviewers []giteaUser  `json:"requested_reviewers"`
+	RequestedTeams     []giteaTeam  `json:"requested_reviewers_teams"`
+}
+
+type giteaBranch struct {
+	Ref    string `json:"ref"`
+	RepoID int64  `json:"repo_id"`
+}
+
+type giteaUser struct {
+	Login string `json:"login"`
+}
+
+type giteaLabel struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type giteaTeam struct {
+	Name string `json:"name"`
+}
+
+func (g *giteaProvider) DefaultBranch(ctx context.Context, repo Repository) (string, error) {
+	var repository struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	err := g.api.do(ctx, http.MethodGet, giteaRepoPath(repo), nil, &repository)
+	if err != nil {
+		return "", err
+	}
+
+	if repository.DefaultBranch == "" {
+		return "", fmt.Errorf("gitea: %s has no default branch", repo.FullName())
+	}
+
+	return repository.DefaultBranch, nil
+}
+
+func (g *giteaProvider) LatestCommit(ctx context.Context, repo Repository) (string, error) {
+	var branch struct {
+		Commit struct {
+			ID string `json:"id"`
+		} `json:"commit"`
+	}
+	err := g.api.do(ctx, http.MethodGet, giteaRepoPath(repo)+"/branches/"+url.PathEscape(repo.DefaultBranch), nil, &branch)
+	if err != nil {
+		return "", err
+	}
+
+	return branch.Commit.ID, nil
+}
+
+func (g *giteaProvider) CloneURL(ctx context.Context, repo Repository) (string, error) {
+	u, err := url.Parse(g.server.url())
+	if err != nil {
+		return "", err
+	}
+
+	// Gitea takes a token in place of the password for any user name
+	u.User = url.UserPassword("stylelia", g.server.Token)
+	u.Path = path.Join(u.Path, repo.FullName()+".git")
+	return u.String(), nil
+}
+
+// The pulls endpoint can't filter by head branch, so page through every open one
+func (g *giteaProvider) FindChangeRequest(ctx context.Context, repo Repository, branch string) (*ChangeRequest, error) {
+	var change *ChangeRequest
+	err := g.eachPull(ctx, repo, "open", func(pr giteaPullRequest) bool {
+		// A fork can have a branch of the same name, those aren't Stylelia's
+		if pr.Head.RepoID == pr.Base.RepoID && pr.Head.Ref == branch {
+			change = pr.change()
+			return false
+		}
+		return true
+	})
+
+	return change, err
+}
+
+func (g *giteaProvider) ListChangeRequests(ctx context.Context, repo Repository, prefix string) ([]*ChangeRequest, error) {
+	var changes []*ChangeRequest
+	err := g.eachPull(ctx, repo, "open", func(pr giteaPullRequest) bool {
+		if pr.Head.RepoID == pr.Base.RepoID && strings.HasPrefix(pr.Head.Ref, prefix) {
+			changes = append(changes, pr.change())
+		}
+		return true
+	})
+
+	return changes, err
+}
+
+// Gitea lists the newest Pull Requests first, so the first from branch is the latest
+func (g *giteaProvider) ChangeRequestDeclined(ctx context.Context, repo Repository, branch string) (bool, error) {
+	var latest giteaPullRequest
+	err := g.eachPull(ctx, repo, "all", func(pr giteaPullRequest) bool {
+		if pr.Head.RepoID == pr.Base.RepoID && pr.Head.Ref == branch {
+			latest = pr
+			return false
+		}
+		return true
+	})
+
+	return latest.State == "closed" && !latest.Merged, err
+}
+
+// Calls fn with every Pull Request in state, open, closed or all, until it returns false
+func (g *giteaProvider) eachPull(ctx context.Context, repo Repository, state string, fn func(giteaPullRequest) bool) error {
+	for page := 1; ; page++ {
+		query := url.Values{
+			"state": {state},
+			"page":  {strconv.Itoa(page)},
+			"limit": {strconv.Itoa(giteaPageSize)},
+		}
+
+		var prs []giteaPullRequest
+		err := g.api.do(ctx, http.MethodGet, giteaRepoPath(repo)+"/pulls?"+query.Encode(), nil, &prs)
+		if err != nil {
+			return err
+		}
+
+		for _, pr := range prs {
+			if !fn(pr) {
+				return nil
+			}
+		}
+
+		if len(prs) < giteaPageSize {
+			return nil
+		}
+	}
+}
+
+func (g *giteaProvider) OpenChangeRequest(ctx context.Context, repo Repository, branch string, details ChangeRequestDetails) (*ChangeRequest, error) {
+	labels, err := g.labelIDs(ctx, repo, details.Labels)
+	if err != nil {
+		return nil, err
+	}
+
+	request := map[string]interface{}{
+		"head":      branch,
+		"base":      repo.DefaultBranch,
+		"title":     details.Title,
+		"body":      details.body(),
+		"assignees": mergeUnique(nil, details.Assignees),
+		"labels":    labels,
+	}
+
+	var pr giteaPullRequest
+	err = g.api.do(ctx, http.MethodPost, giteaRepoPath(repo)+"/pulls", request, &pr)
+	if err != nil {
+		return nil, err
+	}
+
+	change := pr.change()
+	change.Branch = branch
+	err = g.syncReviewers(ctx, repo, pr, details.Reviewers, nil)
+	if err != nil {
+		return change, fmt.Errorf("%w: %v", ErrReviewersNotRequested, err)
+	}
+
+	return change, nil
+}
+
+func (g *giteaProvider) UpdateChangeRequest(ctx context.Context, repo Repository, change *ChangeRequest, details ChangeRequestDetails) error {
+	endpoint := giteaRepoPath(repo) + "/pulls/" + strconv.Itoa(change.Number)
+
+	// Setting labels and assignees replaces them, keep whatever was added since
+	var current giteaPullRequest
+	err := g.api.do(ctx, http.MethodGet, endpoint, nil, &current)
+	if err != nil {
+		return err
+	}
+
+	labels, err := g.labelIDs(ctx, repo, details.Labels)
+	if err != nil {
+		return err
+	}
+	for _, label := range current.Labels {
+		if !containsLabel(labels, label.ID) {
+			labels = append(labels, label.ID)
+		}
+	}
+
+	var assignees []string
+	for _, assignee := range current.Assignees {
+		assignees = append(assignees, assignee.Login)
+	}
+
+	request := map[string]interface{}{
+		"title":     details.Title,
+		"body":      details.body(),
+		"assignees": mergeUnique(assignees, details.Assignees),
+		"labels":    labels,
+	}
+
+	err = g.api.do(ctx, http.MethodPatch, endpoint, request, nil)
+	if err != nil {
+		return err
+	}
+
+	// The body being replaced names the reviewers Stylelia requested last time
+	err = g.syncReviewers(ctx, repo, current, details.Reviewers, requestedReviewers(current.Body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrReviewersNotRequested, err)
+	}
+
+	return nil
+}
+
+func (g *giteaProvider) CloseChangeRequest(ctx context.Context, repo Repository, change *ChangeRequest, comment string) error {
+	number := strconv.Itoa(change.Number)
+	err := g.api.do(ctx, http.MethodPost, giteaRepoPath(repo)+"/issues/"+number+"/comments", map[string]interface{}{"body": comment}, nil)
+	if err != nil {
+		return err
+	}
+
+	err = g.api.do(ctx, http.MethodPatch, giteaRepoPath(repo)+"/pulls/"+number, map[string]interface{}{"state": "closed"}, nil)
+	if err != nil {
+		return err
+	}
+
+	// Someone may have deleted the branch already
+	err = g.api.do(ctx, http.MethodDelete, giteaRepoPath(repo)+"/branches/"+url.PathEscape(change.Branch), nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Requests reviews from reviewers and withdraws the requests Stylelia made
+// before, in previous, for anyone no longer among them
+func (g *giteaProvider) syncReviewers(ctx context.Context, repo Repository, pr giteaPullRequest, reviewers, previous []string) error {
+	users, teams := splitReviewers(repo.Org, reviewers)
+	previousUsers, previousTeams := splitReviewers(repo.Org, previous)
+	var wantUsers []string
+	for _, user := range users {
+		// Gitea refuses to let the author review their own Pull Request
+		if !strings.EqualFold(user, pr.User.Login) {
+			wantUsers = append(wantUsers, user)
+		}
+	}
+
+	staleUsers, staleTeams := []string{}, []string{}
+	for _, user := range pr.RequestedReviewers {
+		if containsFold(previousUsers, user.Login) && !containsFold(wantUsers, user.Login) {
+			staleUsers = append(staleUsers, user.Login)
+		}
+	}
+	for _, team := range pr.RequestedTeams {
+		if containsFold(previousTeams, team.Name) && !containsFold(teams, team.Name) {
+			staleTeams = append(staleTeams, team.Name)
+		}
+	}
+
+	endpoint := giteaRepoPath(repo) + "/pulls/" + strconv.Itoa(pr.Number) + "/requested_reviewers"
+	if len(staleUsers) > 0 || len(staleTeams) > 0 {
+		request := map[string]interface{}{"reviewers": staleUsers, "team_reviewers": staleTeams}
+		err := g.api.do(ctx, http.MethodDelete, endpoint, request, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(wantUsers) > 0 || len(teams) > 0 {
+		request := map[string]interface{}{"reviewers": mergeUnique(nil, wantUsers), "team_reviewers": mergeUnique(nil, teams)}
+		err := g.api.do(ctx, http.MethodPost, endpoint, request, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Gitea takes labels by id, looks up the ids of those that exist in the repository
+func (g *giteaProvider) labelIDs(ctx context.Context, repo Repository, names []string) ([]int64, error) {
+	ids := []int64{}
+	if len(names) == 0 {
+		return ids, nil
+	}
+
+	for page := 1; ; page++ {
+		query := url.Values{"page": {strconv.Itoa(page)}, "limit": {strconv.Itoa(giteaPageSize)}}
+
+		var labels []giteaLabel
+		err := g.api.do(ctx, http.MethodGet, giteaRepoPath(repo)+"/labels?"+query.Encode(), nil, &labels)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, label := range labels {
+			if containsFold(names, label.Name) {
+				ids = append(ids, label.ID)
+			}
+		}
+
+		if len(labels) < giteaPageSize {
+			return ids, nil
+		}
+	}
+}
+
+func containsLabel(ids []int64, id int64) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (pr giteaPullRequest) change() *ChangeRequest {
+	return &ChangeRequest{Number: pr.Number, URL: pr.HTMLURL, Branch: pr.Head.Ref}
+}
+
+func giteaRepoPath(repo Repository) string {
+	return "/repos/" + url.PathEscape(repo.Org) + "/" + url.PathEscape(repo.Name)
+}
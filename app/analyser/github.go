@@ -0,0 +1,322 @@
+package analyser
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v39/github"
+)
+
+// Repositories on github.com or a GitHub Enterprise Server
+type githubProvider struct {
+	client  *github.Client
+	gitHost string
+	// Fetched for every clone as app installation tokens expire
+	token func() (string, error)
+}
+
+func (g *githubProvider) DefaultBranch(ctx context.Context, repo Repository) (string, error) {
+	return getDefaultBranch(ctx, g.client, repo.Org, repo.Name)
+}
+
+func (g *githubProvider) LatestCommit(ctx context.Context, repo Repository) (string, error) {
+	err := repo.getLastCommit(ctx, g.client)
+	return repo.LatestCommit, err
+}
+
+func (g *githubProvider) CloneURL(ctx context.Context, repo Repository) (string, error) {
+	token, err := g.token()
+	if err != nil {
+		return "", err
+	}
+
+	return buildCloneURL(g.gitHost, token, repo), nil
+}
+
+func (g *githubProvider) FindChangeRequest(ctx context.Context, repo Repository, branch string) (*ChangeRequest, error) {
+	// GitHub only matches the head branch when it is qualified with the owner
+	opt := &github.PullRequestListOptions{Head: qualifiedHead(repo, branch), State: "open"}
+	prs, _, err := g.client.PullRequests.List(ctx, repo.Org, repo.Name, opt)
+	if err != nil {
+		return nil, githubError(err)
+	}
+
+	if len(prs) == 0 {
+		return nil, nil
+	}
+
+	return pullRequestChange(prs[0]), nil
+}
+
+func (g *githubProvider) ChangeRequestDeclined(ctx context.Context, repo Repository, branch string) (bool, error) {
+	opt := &github.PullRequestListOptions{
+		Head:        qualifiedHead(repo, branch),
+		State:       "all",
+		Sort:        "created",
+		Direction:   "desc",
+		ListOptions: github.ListOptions{PerPage: 1},
+	}
+	prs, _, err := g.client.PullRequests.List(ctx, repo.Org, repo.Name, opt)
+	if err != nil {
+		return false, githubError(err)
+	}
+
+	if len(prs) == 0 {
+		return false, nil
+	}
+
+	return prs[0].GetState() == "closed" && prs[0].MergedAt == nil, nil
+}
+
+func (g *githubProvider) OpenChangeRequest(ctx context.Context, repo Repository, branch string, details ChangeRequestDetails) (*ChangeRequest, error) {
+	// Cross repository Pull Requests name the fork's owner
+	head := branch
+	if repo.Fork != nil {
+		head = qualifiedHead(repo, branch)
+	}
+	newPr := &github.NewPullRequest{
+		Title:               &details.Title,
+		Head:                &head,
+		Base:                &repo.DefaultBranch,
+		Body:                github.String(details.body()),
+		MaintainerCanModify: github.Bool(true),
+	}
+
+	pr, _, err := g.client.PullRequests.Create(ctx, repo.Org, repo.Name, newPr)
+	if err != nil {
+		return nil, githubError(err)
+	}
+
+	change := pullRequestChange(pr)
+	change.Branch = branch
+	return change, g.decorate(ctx, repo, pr, details, nil)
+}
+
+func (g *githubProvider) UpdateChangeRequest(ctx context.Context, repo Repository, change *ChangeRequest, details ChangeRequestDetails) error {
+	// The body being replaced names the reviewers Stylelia requested last time
+	pr, _, err := g.client.PullRequests.Get(ctx, repo.Org, repo.Name, change.Number)
+	if err != nil {
+		return githubError(err)
+	}
+	previous := requestedReviewers(pr.GetBody())
+
+	pr = &github.PullRequest{Title: &details.Title, Body: github.String(details.body())}
+	pr, _, err = g.client.PullRequests.Edit(ctx, repo.Org, repo.Name, change.Number, pr)
+	if err != nil {
+		return githubError(err)
+	}
+
+	return g.decorate(ctx, repo, pr, details, previous)
+}
+
+// Adds the labels and assignees and syncs the requested reviewers, previous
+// are the reviewers Stylelia requested before
+func (g *githubProvider) decorate(ctx context.Context, repo Repository, pr *github.PullRequest, details ChangeRequestDetails, previous []string) error {
+	if len(details.Labels) > 0 {
+		_, _, err := g.client.Issues.AddLabelsToIssue(ctx, repo.Org, repo.Name, pr.GetNumber(), details.Labels)
+		if err != nil {
+			return githubError(err)
+		}
+	}
+
+	if len(details.Assignees) > 0 {
+		_, _, err := g.client.Issues.AddAssignees(ctx, repo.Org, repo.Name, pr.GetNumber(), details.Assignees)
+		if err != nil {
+			return githubError(err)
+		}
+	}
+
+	err := g.syncReviewers(ctx, repo, pr, details.Reviewers, previous)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrReviewersNotRequested, err)
+	}
+
+	return nil
+}
+
+// Requests reviews from reviewers and withdraws the requests Stylelia made
+// before, in previous, for anyone no longer among them, e.g. when cookstyle
+// stops touching the files they own. Reviewers people added are left alone.
+func (g *githubProvider) syncReviewers(ctx context.Context, repo Repository, pr *github.PullRequest, reviewers, previous []string) error {
+	previousUsers, previousTeams := splitReviewers(repo.Org, previous)
+	users, teams := splitReviewers(repo.Org, reviewers)
+	// GitHub refuses to let the author review their own Pull Request
+	var want github.ReviewersRequest
+	for _, user := range users {
+		if !strings.EqualFold(user, pr.GetUser().GetLogin()) {
+			want.Reviewers = append(want.Reviewers, user)
+		}
+	}
+	want.TeamReviewers = teams
+
+	requested, _, err := g.client.PullRequests.ListReviewers(ctx, repo.Org, repo.Name, pr.GetNumber(), nil)
+	if err != nil {
+		return githubError(err)
+	}
+
+	var stale github.ReviewersRequest
+	for _, user := range requested.Users {
+		if containsFold(previousUsers, user.GetLogin()) && !containsFold(want.Reviewers, user.GetLogin()) {
+			stale.Reviewers = append(stale.Reviewers, user.GetLogin())
+		}
+	}
+	for _, team := range requested.Teams {
+		if containsFold(previousTeams, team.GetSlug()) && !containsFold(want.TeamReviewers, team.GetSlug()) {
+			stale.TeamReviewers = append(stale.TeamReviewers, team.GetSlug())
+		}
+	}
+
+	if len(stale.Reviewers) > 0 || len(stale.TeamReviewers) > 0 {
+		_, err = g.client.PullRequests.RemoveReviewers(ctx, repo.Org, repo.Name, pr.GetNumber(), stale)
+		if err != nil {
+			return githubError(err)
+		}
+	}
+
+	if len(want.Reviewers) > 0 || len(want.TeamReviewers) > 0 {
+		_, _, err = g.client.PullRequests.RequestReviewers(ctx, repo.Org, repo.Name, pr.GetNumber(), want)
+		if err != nil {
+			return githubError(err)
+		}
+	}
+
+	return nil
+}
+
+func (g *githubProvider) ListChangeRequests(ctx context.Context, repo Repository, prefix string) ([]*ChangeRequest, error) {
+	var changes []*ChangeRequest
+	opt := &github.PullRequestListOptions{State: "open", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		prs, response, err := g.client.PullRequests.List(ctx, repo.Org, repo.Name, opt)
+		if err != nil {
+			return nil, githubError(err)
+		}
+
+		for _, pr := range prs {
+			if ownHead(repo, pr) && strings.HasPrefix(pr.GetHead().GetRef(), prefix) {
+				changes = append(changes, pullRequestChange(pr))
+			}
+		}
+
+		if response.NextPage == 0 {
+			return changes, nil
+		}
+		opt.Page = response.NextPage
+	}
+}
+
+func (g *githubProvider) CloseChangeRequest(ctx context.Context, repo Repository, change *ChangeRequest, comment string) error {
+	_, _, err := g.client.Issues.CreateComment(ctx, repo.Org, repo.Name, change.Number, &github.IssueComment{Body: &comment})
+	if err != nil {
+		return githubError(err)
+	}
+
+	_, _, err = g.client.PullRequests.Edit(ctx, repo.Org, repo.Name, change.Number, &github.PullRequest{State: github.String("closed")})
+	if err != nil {
+		return githubError(err)
+	}
+
+	// Someone may have deleted the branch already, GitHub answers that with a 422
+	head := repo.head()
+	_, err = g.client.Git.DeleteRef(ctx, head.Org, head.Name, "refs/heads/"+change.Branch)
+	var responseErr *github.ErrorResponse
+	if errors.As(err, &responseErr) && responseErr.Response != nil && responseErr.Response.StatusCode == http.StatusUnprocessableEntity {
+		return nil
+	}
+	return githubError(err)
+}
+
+// Builds the commit from blobs and a tree on top of the default branch. The
+// commit has no author so GitHub signs it as the token's owner.
+func (g *githubProvider) CommitChanges(ctx context.Context, repo Repository, branch, message string, changes []fileChange) error {
+	parent, _, err := g.client.Git.GetCommit(ctx, repo.Org, repo.Name, repo.LatestCommit)
+	if err != nil {
+		return githubError(err)
+	}
+
+	entries := make([]*github.TreeEntry, 0, len(changes))
+	for _, change := range changes {
+		entry := &github.TreeEntry{
+			Path: github.String(change.Path),
+			Mode: github.String(change.Mode),
+			Type: github.String("blob"),
+		}
+
+		// Entries without a SHA delete the path
+		if !change.Deleted {
+			blob := &github.Blob{
+				Content:  github.String(base64.StdEncoding.EncodeToString(change.Content)),
+				Encoding: github.String("base64"),
+			}
+			blob, _, err = g.client.Git.CreateBlob(ctx, repo.Org, repo.Name, blob)
+			if err != nil {
+				return githubError(err)
+			}
+			entry.SHA = blob.SHA
+		}
+
+		entries = append(entries, entry)
+	}
+
+	tree, _, err := g.client.Git.CreateTree(ctx, repo.Org, repo.Name, parent.GetTree().GetSHA(), entries)
+	if err != nil {
+		return githubError(err)
+	}
+
+	commit := &github.Commit{
+		Message: github.String(message),
+		Tree:    &github.Tree{SHA: tree.SHA},
+		Parents: []*github.Commit{{SHA: github.String(repo.LatestCommit)}},
+	}
+	commit, _, err = g.client.Git.CreateCommit(ctx, repo.Org, repo.Name, commit)
+	if err != nil {
+		return githubError(err)
+	}
+
+	return g.setBranch(ctx, repo, branch, commit.GetSHA())
+}
+
+// Points branch at sha, creating it if it doesn't exist. Like git push -f an
+// existing branch is overwritten.
+func (g *githubProvider) setBranch(ctx context.Context, repo Repository, branch, sha string) error {
+	ref := &github.Reference{
+		Ref:    github.String("refs/heads/" + branch),
+		Object: &github.GitObject{SHA: github.String(sha)},
+	}
+
+	_, _, err := g.client.Git.GetRef(ctx, repo.Org, repo.Name, ref.GetRef())
+	err = githubError(err)
+	if errors.Is(err, ErrNotFound) {
+		_, _, err = g.client.Git.CreateRef(ctx, repo.Org, repo.Name, ref)
+		return githubError(err)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, _, err = g.client.Git.UpdateRef(ctx, repo.Org, repo.Name, ref, true)
+	return githubError(err)
+}
+
+// Qualifies branch with the owner of the repository it is pushed to
+func qualifiedHead(repo Repository, branch string) string {
+	return repo.head().Org + ":" + branch
+}
+
+// Whether pr comes from the repository Stylelia pushes to, a fork of
+// someone else's can have a branch of the same name
+func ownHead(repo Repository, pr *github.PullRequest) bool {
+	if repo.Fork == nil {
+		return pr.GetHead().GetRepo().GetID() == pr.GetBase().GetRepo().GetID()
+	}
+
+	return strings.EqualFold(pr.GetHead().GetRepo().GetFullName(), repo.Fork.FullName())
+}
+
+func pullRequestChange(pr *github.PullRequest) *ChangeRequest {
+	return &ChangeRequest{Number: pr.GetNumber(), URL: pr.GetHTMLURL(), Branch: pr.GetHead().GetRef()}
+}
@@ -0,0 +1,216 @@
+package analyser
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v39/github"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// GitHub rejects app JWTs that live longer than 10 minutes
+	appJWTLifetime time.Duration = 9 * time.Minute
+	// Installation tokens last an hour, hand out a new one well before
+	// that so a clone or push never starts with a token about to expire
+	installationTokenRefresh time.Duration = 10 * time.Minute
+)
+
+// Hands out the token used to talk to GitHub on behalf of an organisation
+type TokenProvider interface {
+	TokenSource(ctx context.Context, org string) oauth2.TokenSource
+}
+
+// A personal access token, the same for every organisation
+type staticToken string
+
+func (s staticToken) TokenSource(ctx context.Context, org string) oauth2.TokenSource {
+	return oauth2.StaticTokenSource(&oauth2.Token{AccessToken: string(s)})
+}
+
+// Authenticates as a GitHub App, exchanging a JWT signed with the app's
+// private key for short lived tokens of the installation on each organisation
+type GithubApp struct {
+	ID         int64
+	PrivateKey *rsa.PrivateKey
+
+	// Authenticated as the app itself, used to find installations and create tokens
+	client *github.Client
+	now    func() time.Time
+
+	mu            sync.Mutex
+	installations map[string]int64
+	tokens        map[int64]*github.InstallationToken
+}
+
+// Creates the app registered on server, github.com if it's the zero value
+func NewGithubApp(id int64, privateKeyPEM []byte, server GithubServer) (*GithubApp, error) {
+	privateKey, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	app := &GithubApp{
+		ID:            id,
+		PrivateKey:    privateKey,
+		now:           time.Now,
+		installations: make(map[string]int64),
+		tokens:        make(map[int64]*github.InstallationToken),
+	}
+	app.client, err = server.client(&http.Client{Transport: &appTransport{app: app}})
+	if err != nil {
+		return nil, err
+	}
+
+	return app, nil
+}
+
+func (a *GithubApp) TokenSource(ctx context.Context, org string) oauth2.TokenSource {
+	return &appTokenSource{ctx: ctx, app: a, org: org}
+}
+
+// Returns a token for the app's installation on org, reusing the last one
+// until it gets close to expiring
+func (a *GithubApp) Token(ctx context.Context, org string) (*oauth2.Token, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	id, ok := a.installations[org]
+	if !ok {
+		installation, err := a.findInstallation(ctx, org)
+		if err != nil {
+			return nil, err
+		}
+		id = installation.GetID()
+		a.installations[org] = id
+	}
+
+	token, ok := a.tokens[id]
+	if !ok || a.now().Add(installationTokenRefresh).After(token.GetExpiresAt()) {
+		var err error
+		token, _, err = a.client.Apps.CreateInstallationToken(ctx, id, nil)
+		if err != nil {
+			return nil, githubError(err)
+		}
+		a.tokens[id] = token
+	}
+
+	return &oauth2.Token{
+		AccessToken: token.GetToken(),
+		// Expire early so the oauth2 client comes back for a fresh one
+		Expiry: token.GetExpiresAt().Add(-installationTokenRefresh),
+	}, nil
+}
+
+// Finds the installation on an organisation, falling back to a user account
+func (a *GithubApp) findInstallation(ctx context.Context, org string) (*github.Installation, error) {
+	installation, _, err := a.client.Apps.FindOrganizationInstallation(ctx, org)
+	if err == nil {
+		return installation, nil
+	}
+
+	err = githubError(err)
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	installation, _, err = a.client.Apps.FindUserInstallation(ctx, org)
+	if err != nil {
+		return nil, fmt.Errorf("app not installed on %s: %w", org, githubError(err))
+	}
+
+	return installation, nil
+}
+
+// Signs a JWT identifying the app, valid for appJWTLifetime
+func (a *GithubApp) jwt() (string, error) {
+	now := a.now()
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	claims := map[string]interface{}{
+		// Backdated to allow for clock drift between us and GitHub
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+		"iss": strconv.FormatInt(a.ID, 10),
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.PrivateKey, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// GitHub hands out PKCS#1 keys, accept PKCS#8 as well for keys that have been converted
+func parsePrivateKey(privateKeyPEM []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, errors.New("github app: private key is not PEM encoded")
+	}
+
+	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err == nil {
+		return privateKey, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("github app: unable to parse private key: %v", err)
+	}
+	privateKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("github app: private key is not an RSA key")
+	}
+
+	return privateKey, nil
+}
+
+// Authenticates requests as the app itself with a fresh JWT
+type appTransport struct {
+	app *GithubApp
+}
+
+func (t *appTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	jwt, err := t.app.jwt()
+	if err != nil {
+		return nil, err
+	}
+
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+jwt)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+type appTokenSource struct {
+	ctx context.Context
+	app *GithubApp
+	org string
+}
+
+func (s *appTokenSource) Token() (*oauth2.Token, error) {
+	return s.app.Token(s.ctx, s.org)
+}
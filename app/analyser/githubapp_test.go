@@ -0,0 +1,176 @@
+package analyser
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPrivateKey(t *testing.T) *rsa.PrivateKey {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privateKey
+}
+
+// Checks the signature of a JWT and returns its claims
+func verifyJWT(t *testing.T, jwt string, publicKey *rsa.PublicKey) map[string]interface{} {
+	parts := strings.Split(jwt, ".")
+	if !assert.Len(t, parts, 3) {
+		return nil
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	assert.NoError(t, err)
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	assert.NoError(t, rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hash[:], signature))
+
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	assert.NoError(t, err)
+	var claims map[string]interface{}
+	assert.NoError(t, json.Unmarshal(claimsJSON, &claims))
+	return claims
+}
+
+func TestGithubAppJWT(t *testing.T) {
+	privateKey := newTestPrivateKey(t)
+	privateKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+
+	app, err := NewGithubApp(1234, privateKeyPEM, GithubServer{})
+	assert.NoError(t, err)
+	now := time.Date(2021, 10, 18, 12, 0, 0, 0, time.UTC)
+	app.now = func() time.Time { return now }
+
+	jwt, err := app.jwt()
+	assert.NoError(t, err)
+
+	claims := verifyJWT(t, jwt, &privateKey.PublicKey)
+	assert.Equal(t, "1234", claims["iss"])
+	assert.Equal(t, float64(now.Add(-time.Minute).Unix()), claims["iat"])
+	assert.Equal(t, float64(now.Add(9*time.Minute).Unix()), claims["exp"])
+}
+
+func TestGithubAppToken(t *testing.T) {
+	privateKey := newTestPrivateKey(t)
+	privateKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+
+	now := time.Date(2021, 10, 18, 12, 0, 0, 0, time.UTC)
+	var tokensCreated int32
+
+	// Fake token endpoint
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/stylelia/installation", func(w http.ResponseWriter, r *http.Request) {
+		verifyJWT(t, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), &privateKey.PublicKey)
+		fmt.Fprint(w, `{"id": 42}`)
+	})
+	mux.HandleFunc("/orgs/xorima/installation", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+	})
+	mux.HandleFunc("/users/xorima/installation", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 7}`)
+	})
+	mux.HandleFunc("/orgs/empire/installation", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+	})
+	mux.HandleFunc("/users/empire/installation", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+	})
+	mux.HandleFunc("/app/installations/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		verifyJWT(t, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), &privateKey.PublicKey)
+		created := atomic.AddInt32(&tokensCreated, 1)
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/app/installations/"), "/access_tokens")
+		fmt.Fprintf(w, `{"token": "ghs_%s_%d", "expires_at": %q}`, id, created, now.Add(time.Hour).Format(time.RFC3339))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	app, err := NewGithubApp(1234, privateKeyPEM, GithubServer{})
+	assert.NoError(t, err)
+	app.client.BaseURL = newTestGithubClient(server).BaseURL
+	app.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	t.Run("Exchanges a JWT for an installation token", func(t *testing.T) {
+		token, err := app.TokenSource(ctx, "stylelia").Token()
+		assert.NoError(t, err)
+		assert.Equal(t, "ghs_42_1", token.AccessToken)
+		assert.Equal(t, now.Add(50*time.Minute), token.Expiry)
+	})
+
+	t.Run("Reuses the token until it is about to expire", func(t *testing.T) {
+		token, err := app.Token(ctx, "stylelia")
+		assert.NoError(t, err)
+		assert.Equal(t, "ghs_42_1", token.AccessToken)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&tokensCreated))
+	})
+
+	t.Run("Refreshes the token before it expires", func(t *testing.T) {
+		now = now.Add(55 * time.Minute)
+
+		token, err := app.Token(ctx, "stylelia")
+		assert.NoError(t, err)
+		assert.Equal(t, "ghs_42_2", token.AccessToken)
+	})
+
+	t.Run("Falls back to the installation on a user account", func(t *testing.T) {
+		token, err := app.Token(ctx, "xorima")
+		assert.NoError(t, err)
+		assert.Equal(t, "ghs_7_3", token.AccessToken)
+	})
+
+	t.Run("Errors when the app isn't installed", func(t *testing.T) {
+		_, err := app.Token(ctx, "empire")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	privateKey := newTestPrivateKey(t)
+
+	t.Run("Parses a PKCS#1 key", func(t *testing.T) {
+		privateKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+
+		actual, err := parsePrivateKey(privateKeyPEM)
+		assert.NoError(t, err)
+		assert.True(t, privateKey.Equal(actual))
+	})
+
+	t.Run("Parses a PKCS#8 key", func(t *testing.T) {
+		der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		assert.NoError(t, err)
+		privateKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+		actual, err := parsePrivateKey(privateKeyPEM)
+		assert.NoError(t, err)
+		assert.True(t, privateKey.Equal(actual))
+	})
+
+	t.Run("Errors on something that isn't PEM", func(t *testing.T) {
+		_, err := parsePrivateKey([]byte("not a key"))
+		assert.EqualError(t, err, "github app: private key is not PEM encoded")
+	})
+}
+
+func TestStaticToken(t *testing.T) {
+	token, err := staticToken("ghp_token").TokenSource(context.Background(), "stylelia").Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "ghp_token", token.AccessToken)
+}
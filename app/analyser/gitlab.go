@@ -0,0 +1,339 @@
+package analyser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const gitlabURL string = "https://gitlab.com"
+
+// GitLab's largest page size
+const gitlabPageSize int = 100
+
+// A GitLab instance hosting some of the repositories
+type GitlabServer struct {
+	// Defaults to https://gitlab.com
+	URL string `yaml:"url"`
+	// Personal, group or project access token with the api and write_repository scopes
+	Token string `yaml:"token"`
+}
+
+func (s GitlabServer) url() string {
+	if s.URL == "" {
+		return gitlabURL
+	}
+
+	return strings.TrimSuffix(s.URL, "/")
+}
+
+func (s GitlabServer) validate() error {
+	if s.Token == "" {
+		return fmt.Errorf("missing token for %s", s.url())
+	}
+
+	u, err := url.Parse(s.url())
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%q is not an absolute URL", s.URL)
+	}
+
+	return nil
+}
+
+// Repositories on GitLab, talking to its REST API directly. Organisations
+// are GitLab groups, subgroups included.
+type gitlabProvider struct {
+	api    restAPI
+	server GitlabServer
+}
+
+func newGitlabProvider(client *http.Client, server GitlabServer) *gitlabProvider {
+	api := restAPI{
+		name:       "gitlab",
+		client:     client,
+		baseURL:    server.url() + "/api/v4",
+		authHeader: "PRIVATE-TOKEN",
+		authValue:  server.Token,
+	}
+
+	return &gitlabProvider{api: api, server: server}
+}
+
+type gitlabMergeRequest struct {
+	IID    int    `json:"iid"`
+	WebURL string `json:"web_url"`
+	// opened, closed, locked or merged
+	State           string       `json:"state"`
+	SourceBranch    string       `json:"source_branch"`
+	SourceProjectID int          `json:"source_project_id"`
+	TargetProjectID int          `json:"target_project_id"`
+	Description     string       `json:"description"`
+	Assignees       []gitlabUser `json:"assignees"`
+	Reviewers       []gitlabUser `json:"reviewers"`
+}
+
+type gitlabUser struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+func (g *gitlabProvider) DefaultBranch(ctx context.Context, repo Repository) (string, error) {
+	var project struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	err := g.api.do(ctx, http.MethodGet, projectPath(repo), nil, &project)
+	if err != nil {
+		return "", err
+	}
+
+	if project.DefaultBranch == "" {
+		return "", fmt.Errorf("gitlab: %s has no default branch", repo.FullName())
+	}
+
+	return project.DefaultBranch, nil
+}
+
+func (g *gitlabProvider) LatestCommit(ctx context.Context, repo Repository) (string, error) {
+	var branch struct {
+		Commit struct {
+			ID string `json:"id"`
+		} `json:"commit"`
+	}
+	err := g.api.do(ctx, http.MethodGet, projectPath(repo)+"/repository/branches/"+url.PathEscape(repo.DefaultBranch), nil, &branch)
+	if err != nil {
+		return "", err
+	}
+
+	return branch.Commit.ID, nil
+}
+
+func (g *gitlabProvider) CloneURL(ctx context.Context, repo Repository) (string, error) {
+	u, err := url.Parse(g.server.url())
+	if err != nil {
+		return "", err
+	}
+
+	u.User = url.UserPassword("oauth2", g.server.Token)
+	u.Path = path.Join(u.Path, repo.FullName()+".git")
+	return u.String(), nil
+}
+
+func (g *gitlabProvider) FindChangeRequest(ctx context.Context, repo Repository, branch string) (*ChangeRequest, error) {
+	query := url.Values{"state": {"opened"}, "source_branch": {branch}}
+
+	var mrs []gitlabMergeRequest
+	err := g.api.do(ctx, http.MethodGet, projectPath(repo)+"/merge_requests?"+query.Encode(), nil, &mrs)
+	if err != nil {
+		return nil, err
+	}
+
+	// A fork can have a branch of the same name, those aren't Stylelia's
+	for _, mr := range mrs {
+		if mr.SourceProjectID == mr.TargetProjectID {
+			return mr.change(), nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (g *gitlabProvider) ChangeRequestDeclined(ctx context.Context, repo Repository, branch string) (bool, error) {
+	query := url.Values{
+		"source_branch": {branch},
+		"order_by":      {"created_at"},
+		"sort":          {"desc"},
+		"per_page":      {strconv.Itoa(gitlabPageSize)},
+	}
+
+	var mrs []gitlabMergeRequest
+	err := g.api.do(ctx, http.MethodGet, projectPath(repo)+"/merge_requests?"+query.Encode(), nil, &mrs)
+	if err != nil {
+		return false, err
+	}
+
+	// Skipping Merge Requests from forks with a branch of the same name
+	for _, mr := range mrs {
+		if mr.SourceProjectID == mr.TargetProjectID {
+			return mr.State == "closed", nil
+		}
+	}
+
+	return false, nil
+}
+
+func (g *gitlabProvider) OpenChangeRequest(ctx context.Context, repo Repository, branch string, details ChangeRequestDetails) (*ChangeRequest, error) {
+	assignees, err := g.userIDs(ctx, details.Assignees)
+	if err != nil {
+		return nil, err
+	}
+	reviewers, err := g.reviewerIDs(ctx, details.Reviewers)
+	if err != nil {
+		return nil, err
+	}
+
+	request := map[string]interface{}{
+		"source_branch":        branch,
+		"target_branch":        repo.DefaultBranch,
+		"title":                details.Title,
+		"description":          details.body(),
+		"remove_source_branch": true,
+		"labels":               strings.Join(details.Labels, ","),
+		"assignee_ids":         assignees,
+		"reviewer_ids":         reviewers,
+	}
+
+	var mr gitlabMergeRequest
+	err = g.api.do(ctx, http.MethodPost, projectPath(repo)+"/merge_requests", request, &mr)
+	if err != nil {
+		return nil, err
+	}
+
+	change := mr.change()
+	change.Branch = branch
+	return change, nil
+}
+
+func (g *gitlabProvider) UpdateChangeRequest(ctx context.Context, repo Repository, change *ChangeRequest, details ChangeRequestDetails) error {
+	endpoint := projectPath(repo) + "/merge_requests/" + strconv.Itoa(change.Number)
+
+	// Setting assignees replaces them, keep whoever was assigned since
+	var current gitlabMergeRequest
+	err := g.api.do(ctx, http.MethodGet, endpoint, nil, &current)
+	if err != nil {
+		return err
+	}
+	assignees, err := g.userIDs(ctx, details.Assignees)
+	if err != nil {
+		return err
+	}
+	for _, assignee := range current.Assignees {
+		if !containsID(assignees, assignee.ID) {
+			assignees = append(assignees, assignee.ID)
+		}
+	}
+
+	// Setting reviewers replaces them too, only drop those Stylelia requested
+	// last time, as named in the description being replaced
+	reviewers, err := g.reviewerIDs(ctx, details.Reviewers)
+	if err != nil {
+		return err
+	}
+	previous := requestedReviewers(current.Description)
+	for _, reviewer := range current.Reviewers {
+		if !containsFold(previous, reviewer.Username) && !containsID(reviewers, reviewer.ID) {
+			reviewers = append(reviewers, reviewer.ID)
+		}
+	}
+
+	request := map[string]interface{}{
+		"title":        details.Title,
+		"description":  details.body(),
+		"add_labels":   strings.Join(details.Labels, ","),
+		"assignee_ids": assignees,
+		"reviewer_ids": reviewers,
+	}
+
+	return g.api.do(ctx, http.MethodPut, endpoint, request, nil)
+}
+
+func (g *gitlabProvider) ListChangeRequests(ctx context.Context, repo Repository, prefix string) ([]*ChangeRequest, error) {
+	var changes []*ChangeRequest
+	for page := 1; ; page++ {
+		query := url.Values{
+			"state":    {"opened"},
+			"page":     {strconv.Itoa(page)},
+			"per_page": {strconv.Itoa(gitlabPageSize)},
+		}
+
+		var mrs []gitlabMergeRequest
+		err := g.api.do(ctx, http.MethodGet, projectPath(repo)+"/merge_requests?"+query.Encode(), nil, &mrs)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, mr := range mrs {
+			if mr.SourceProjectID == mr.TargetProjectID && strings.HasPrefix(mr.SourceBranch, prefix) {
+				changes = append(changes, mr.change())
+			}
+		}
+
+		if len(mrs) < gitlabPageSize {
+			return changes, nil
+		}
+	}
+}
+
+func (g *gitlabProvider) CloseChangeRequest(ctx context.Context, repo Repository, change *ChangeRequest, comment string) error {
+	endpoint := projectPath(repo) + "/merge_requests/" + strconv.Itoa(change.Number)
+
+	err := g.api.do(ctx, http.MethodPost, endpoint+"/notes", map[string]interface{}{"body": comment}, nil)
+	if err != nil {
+		return err
+	}
+
+	err = g.api.do(ctx, http.MethodPut, endpoint, map[string]interface{}{"state_event": "close"}, nil)
+	if err != nil {
+		return err
+	}
+
+	// Someone may have deleted the branch already
+	err = g.api.do(ctx, http.MethodDelete, projectPath(repo)+"/repository/branches/"+url.PathEscape(change.Branch), nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// GitLab can't request reviews from groups, only from the users among reviewers
+func (g *gitlabProvider) reviewerIDs(ctx context.Context, reviewers []string) ([]int, error) {
+	var users []string
+	for _, reviewer := range reviewers {
+		if !strings.Contains(reviewer, "/") {
+			users = append(users, reviewer)
+		}
+	}
+
+	return g.userIDs(ctx, users)
+}
+
+// Looks up the ids of users by username, leaving out anyone GitLab doesn't know
+func (g *gitlabProvider) userIDs(ctx context.Context, usernames []string) ([]int, error) {
+	ids := []int{}
+	for _, username := range usernames {
+		var users []gitlabUser
+		err := g.api.do(ctx, http.MethodGet, "/users?"+url.Values{"username": {username}}.Encode(), nil, &users)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(users) > 0 && !containsID(ids, users[0].ID) {
+			ids = append(ids, users[0].ID)
+		}
+	}
+
+	return ids, nil
+}
+
+func containsID(ids []int, id int) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (mr gitlabMergeRequest) change() *ChangeRequest {
+	return &ChangeRequest{Number: mr.IID, URL: mr.WebURL, Branch: mr.SourceBranch}
+}
+
+// Projects are addressed by their URL encoded path, e.g. stylelia%2Fsnort
+func projectPath(repo Repository) string {
+	return "/projects/" + url.PathEscape(repo.FullName())
+}
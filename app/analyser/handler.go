@@ -2,23 +2,24 @@ package analyser
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/exec"
-	"strconv"
+	"path/filepath"
 
 	"github.com/google/go-github/v39/github"
 	"github.com/styleila/analyser/pkg/redis"
 	"github.com/youshy/logger"
 	"go.uber.org/zap"
+	"golang.org/x/oauth2"
 )
 
 const (
 	Commit       string = "Commit"
 	Cookstyle    string = "Cookstyle"
 	WorkingDir   string = "/tmp" // Only wriable location in lambda
-	githubApi    string = "https://api.github.com"
 	cookstyleApi string = "https://rubygems.org/api/v1/versions/cookstyle/latest.json"
 )
 
@@ -28,72 +29,228 @@ type KeyValueStore interface {
 	UpdateCommitSha(context.Context, string, string, string) error
 	GetToolVersion(context.Context, string, string, string) (string, error)
 	UpdateToolVersion(context.Context, string, string, string, string) error
+	GetGlobalToolVersion(context.Context, string) (string, error)
+	UpdateGlobalToolVersion(context.Context, string, string) error
+	// Version of a tool whose PR was closed without merging in a repository
+	GetDeclinedVersion(context.Context, string, string, string) (string, error)
+	UpdateDeclinedVersion(context.Context, string, string, string, string) error
+	// Version of a tool whose PR the recreate command closed to raise it again
+	GetRecreatedVersion(context.Context, string, string, string) (string, error)
+	UpdateRecreatedVersion(context.Context, string, string, string, string) error
+	// Cops of a tool turned off in a repository through a PR comment
+	GetIgnoredCops(context.Context, string, string, string) ([]string, error)
+	UpdateIgnoredCops(context.Context, string, string, string, []string) error
+	// Number of the issue tracking what a tool couldn't correct in a repository
+	GetTrackingIssue(context.Context, string, string, string) (int, error)
+	UpdateTrackingIssue(context.Context, string, string, string, int) error
+	ListRepositories(context.Context) ([]string, error)
+	GetETag(context.Context, string) (string, string, error)
+	UpdateETag(context.Context, string, string, string) error
 }
 
 type Handler struct {
 	Client *http.Client
 	Log    *zap.SugaredLogger
+	Config Config
+	Store  KeyValueStore
+	Tokens TokenProvider
+	// Shared by every GitHub client, nil to skip rate limiting and ETags
+	rateLimits *rateLimiter
+	// Repositories are cloned below this directory
+	WorkingDir string
+	// Picks the provider for a repository, swapped out in tests
+	providerFor func(ctx context.Context, org, name string) (Provider, error)
+	// Analyses a single repository, swapped out in tests
+	analyseRepo func(h *Handler, ctx context.Context, event Event) (Outcome, *DryRun, error)
+	// Where releases send their repositories, nil to run them inline
+	queue eventQueue
 }
 
-func NewHandler(client *http.Client, log *zap.SugaredLogger) Handler {
+func NewHandler(client *http.Client, log *zap.SugaredLogger, config Config) Handler {
 	return Handler{
-		Client: client,
-		Log:    log,
+		Client:     client,
+		Log:        log,
+		Config:     config,
+		Store:      redis.NewRedis(config.RedisPort, config.RedisHost, config.RedisPassword),
+		Tokens:     staticToken(config.GithubToken),
+		rateLimits: newRateLimiter(),
+		WorkingDir: WorkingDir,
 	}
 }
 
-func HandleEvent() error {
+// Builds the handler for a validated config, authenticating as a GitHub App if one is configured
+func newHandlerFromConfig(config Config) (Handler, error) {
 	client := &http.Client{}
 	// TODO: make as env flags
 	logger := logger.NewLogger(logger.DEBUG, false)
 
-	handler := NewHandler(client, logger)
+	handler := NewHandler(client, logger, config)
 
-	return handler.handle()
+	if config.GithubAppID != 0 {
+		privateKey, err := config.githubAppPrivateKey()
+		if err != nil {
+			return handler, err
+		}
+
+		app, err := NewGithubApp(config.GithubAppID, privateKey, config.defaultGithubServer())
+		if err != nil {
+			return handler, err
+		}
+		handler.Tokens = app
+	}
+
+	if config.ReleaseQueueURL != "" {
+		queue, err := newSQSQueue(context.Background(), config.ReleaseQueueURL)
+		if err != nil {
+			return handler, err
+		}
+		handler.queue = queue
+	}
+
+	return handler, nil
 }
 
-func (h *Handler) handle() error {
-	// Fetch the latest default commit sha and check it against cache
-	org := os.Getenv("ORGANISATION")
-	name := os.Getenv("NAME")
+func HandleEvent(ctx context.Context, event Event) (Result, error) {
+	config, err := LoadConfigFromEnv()
+	if err != nil {
+		return Result{}, err
+	}
 
-	githubDefaultBranchEndpoint := fmt.Sprintf("%s/repos/%s/%s", githubApi, org, name)
+	return Run(ctx, config, event)
+}
 
-	branch, err := getDefaultBranch(githubDefaultBranchEndpoint, h.Client)
+// Runs the analyser for an event, failing straight away if the config is incomplete
+func Run(ctx context.Context, config Config, event Event) (Result, error) {
+	err := config.Validate()
 	if err != nil {
-		h.Log.Errorf("Unable to get default branch: %v", err)
-		return err
+		return Result{}, err
 	}
-	h.Log.Infof("Got default branch: %s", branch)
 
-	repo := NewRepo(org, name, branch)
+	handler, err := newHandlerFromConfig(config)
+	if err != nil {
+		return Result{}, err
+	}
 
-	githubLastCommitEndpoint := repo.buildCommitEndpoint(githubApi)
+	return handler.handle(ctx, event)
+}
 
-	err = repo.getLastCommit(githubLastCommitEndpoint, h.Client)
+// Returns a GitHub client acting on behalf of org, talking to whichever
+// server the repository lives on. name may be empty to act on the whole organisation.
+func (h *Handler) githubClient(ctx context.Context, org, name string) (*github.Client, error) {
+	server := h.Config.githubServer(org, name)
+	tc := oauth2.NewClient(ctx, h.tokenSource(ctx, server, org))
+	if h.rateLimits != nil {
+		tc.Transport = &rateLimitTransport{
+			base:    tc.Transport,
+			budget:  server.gitHost() + "/" + org,
+			limiter: h.rateLimits,
+			store:   h.Store,
+			log:     h.Log,
+		}
+	}
+
+	return server.client(tc)
+}
+
+// Returns the token to use for git operations against a repository
+func (h *Handler) githubToken(ctx context.Context, org, name string) (string, error) {
+	server := h.Config.githubServer(org, name)
+	token, err := h.tokenSource(ctx, server, org).Token()
 	if err != nil {
-		h.Log.Errorf("Unable to get latest commit: %v", err)
-		return err
+		return "", err
 	}
 
-	// Setup redis
-	portRaw := os.Getenv("REDIS_PORT")
-	server := os.Getenv("REDIS_HOST")
-	password := os.Getenv("REDIS_PASSWORD")
+	return token.AccessToken, nil
+}
+
+// Servers with their own token use it, everything else goes through h.Tokens
+func (h *Handler) tokenSource(ctx context.Context, server GithubServer, org string) oauth2.TokenSource {
+	if server.Token != "" {
+		return staticToken(server.Token).TokenSource(ctx, org)
+	}
 
-	port, err := strconv.Atoi(portRaw)
+	return h.Tokens.TokenSource(ctx, org)
+}
+
+func (h *Handler) handle(ctx context.Context, event Event) (Result, error) {
+	event = event.withDefaults(h.Config)
+	err := event.validate()
 	if err != nil {
-		h.Log.Errorf("Unable to convert port: %v", err)
-		return err
+		h.Log.Errorf("Invalid event: %v", err)
+		return Result{}, err
+	}
+
+	if !event.runsTool(Cookstyle) {
+		h.Log.Info("No tools to run, nothing to do")
+		return Result{}, nil
+	}
+
+	if event.Release {
+		return h.fanOut(ctx, event)
+	}
+
+	names := []string{event.Name}
+	if event.Scan {
+		_, onGitlab := h.Config.gitlabServer(event.Organisation, "")
+		_, onGitea := h.Config.giteaServer(event.Organisation, "")
+		if onGitlab || onGitea {
+			h.Log.Errorf("Unable to scan %s: %v", event.Organisation, errScanUnsupported)
+			return Result{}, errScanUnsupported
+		}
+
+		client, err := h.githubClient(ctx, event.Organisation, "")
+		if err != nil {
+			h.Log.Errorf("Unable to create GitHub client: %v", err)
+			return Result{}, err
+		}
+
+		names, err = listCookbookRepos(ctx, client, event.Organisation, event.Marker)
+		if err != nil {
+			h.Log.Errorf("Unable to list cookbooks in %s: %v", event.Organisation, err)
+			return Result{}, err
+		}
+		h.Log.Infof("Found %d cookbooks in %s", len(names), event.Organisation)
+	}
+
+	repos := make([]Repository, 0, len(names))
+	for _, name := range names {
+		repos = append(repos, NewRepo(event.Organisation, name, ""))
+	}
+
+	result := h.runPool(ctx, event, repos)
+	return result, result.err()
+}
+
+// Runs the analyser against the single repository named in the event
+func (h *Handler) analyse(ctx context.Context, event Event) (Outcome, *DryRun, error) {
+	// Fetch the latest default commit sha and check it against cache
+	org := event.Organisation
+	name := event.Name
+
+	provider, err := h.provider(ctx, org, name)
+	if err != nil {
+		h.Log.Errorf("Unable to create provider: %v", err)
+		return Failed, nil, err
 	}
 
-	redis := redis.NewRedis(uint16(port), server, password)
-	ctx := context.Background()
+	repo := NewRepo(org, name, "")
+	repo.DefaultBranch, err = provider.DefaultBranch(ctx, repo)
+	if err != nil {
+		h.Log.Errorf("Unable to get default branch: %v", err)
+		return Failed, nil, err
+	}
+	h.Log.Infof("Got default branch: %s", repo.DefaultBranch)
+
+	repo.LatestCommit, err = provider.LatestCommit(ctx, repo)
+	if err != nil {
+		h.Log.Errorf("Unable to get latest commit: %v", err)
+		return Failed, nil, err
+	}
 
-	latestCommit, err := redis.GetCommitSha(ctx, org, name)
+	latestCommit, err := h.Store.GetCommitSha(ctx, org, name)
 	if err != nil {
 		h.Log.Errorf("Unable to get commit sha from Redis: %v", err)
-		return err
+		return Failed, nil, err
 	}
 
 	// Check cache for cookstyle for a given repo.
@@ -101,137 +258,413 @@ func (h *Handler) handle() error {
 	cookstyleVersion, err := getLatestCookstyle(cookstyleApi, h.Client)
 	if err != nil {
 		h.Log.Errorf("Unable to get latest cookstyle version: %v", err)
-		return err
+		return Failed, nil, err
 	}
 
-	latestCookstyle, err := redis.GetToolVersion(ctx, org, name, Cookstyle)
+	latestCookstyle, err := h.Store.GetToolVersion(ctx, org, name, Cookstyle)
 	if err != nil {
 		h.Log.Errorf("Unable to get latest cookstyle version from Redis: %v", err)
-		return err
+		return Failed, nil, err
 	}
 
-	if repo.LatestCommit == latestCommit && cookstyleVersion == latestCookstyle {
+	if !event.Force && repo.LatestCommit == latestCommit && cookstyleVersion == latestCookstyle {
 		// log that we're ending the lifecycle here
 		h.Log.Info("All up to date!")
-		return nil
+		return Skipped, nil, nil
+	}
+
+	branchName := createBranchName(cookstyleVersion)
+	raisePullRequests := h.Config.raisesPullRequests(org, name)
+	if raisePullRequests && h.Config.Forks {
+		if _, ok := provider.(forker); !ok {
+			h.Log.Info("Forks aren't supported for this repository, pushing to it instead")
+		} else {
+			// Only looked up for now, it's created when there's something to push
+			repo.Fork, err = forkFor(ctx, provider, repo, false)
+			if err != nil {
+				h.Log.Errorf("Unable to look up fork: %v", err)
+				return Failed, nil, err
+			}
+		}
+	}
+	if raisePullRequests && !event.Force {
+		declined, err := h.declined(ctx, provider, repo, branchName, cookstyleVersion, event.DryRun)
+		if err != nil {
+			h.Log.Errorf("Unable to check for a declined PR: %v", err)
+			return Failed, nil, err
+		}
+		if declined {
+			h.Log.Infof("The PR for Cookstyle %s was closed without merging, not raising it again", cookstyleVersion)
+			return Skipped, nil, nil
+		}
 	}
 	h.Log.Info("Processing changes...")
 
 	// If not exists or version is different or sha is different, clone the repo
-	repoUri := fmt.Sprintf("https://%s@github.com/%s/%s.git", os.Getenv("GITHUB_TOKEN"), repo.Org, repo.Name)
-	cloneRepoRunner := exec.Command("git", "clone", repoUri, WorkingDir)
-	cloneRepoRunner.Dir = WorkingDir
+	// Each repo gets its own directory so a scan doesn't clone on top of the last one
+	workDir := filepath.Join(h.WorkingDir, repo.Org, repo.Name)
+	err = os.RemoveAll(workDir)
+	if err != nil {
+		h.Log.Errorf("Unable to clean working directory: %v", err)
+		return Failed, nil, err
+	}
+	defer os.RemoveAll(workDir)
+
+	repoUri, err := provider.CloneURL(ctx, repo)
+	if err != nil {
+		h.Log.Errorf("Unable to get clone URL: %v", err)
+		return Failed, nil, err
+	}
+	cloneRepoRunner := exec.Command("git", "clone", repoUri, workDir)
+	cloneRepoRunner.Dir = h.WorkingDir
 	err = repo.Clone(cloneRepoRunner)
 	if err != nil {
 		h.Log.Errorf("Unable to clone repo: %v", err)
-		return err
+		return Failed, nil, err
+	}
+
+	ignoredCops, err := h.Store.GetIgnoredCops(ctx, org, name, Cookstyle)
+	if err != nil {
+		h.Log.Errorf("Unable to get ignored cops from Redis: %v", err)
+		return Failed, nil, err
+	}
+	if len(ignoredCops) > 0 {
+		// Goes into the PR with the fixes, so merging it turns the cops off for good
+		err = disableCops(workDir, ignoredCops)
+		if err != nil {
+			h.Log.Errorf("Unable to turn off ignored cops: %v", err)
+			return Failed, nil, err
+		}
 	}
 
 	h.Log.Info("Running cookstyle...")
 	// run 'cookstyle -a --format json'
 	runner := exec.Command("cookstyle", "-a", "--format", "json")
-	runner.Dir = WorkingDir
+	runner.Dir = workDir
 	out, err := runCookstyle(runner)
 	if err != nil {
 		h.Log.Errorf("Unable to run cookstyle: %v", err)
-		return err
+		return Failed, nil, err
 	}
 
 	h.Log.Info("Creating PR...")
-	// If cookstyle finds a change, create a new branch 'styleila/cookstyle_<version>'
-	branchName := createBranchName(cookstyleVersion)
-	branchRunner := buildBranchCommand(branchName)
-	branchRunner.Dir = WorkingDir
 	title := fmt.Sprintf("Stylelia: Cookstyle %s updates", cookstyleVersion)
 	message := out.PrintMessage(cookstyleVersion)
 
-	if out.Summary.OffenseCount > 0 {
-		err = gitCmdRunner(branchRunner)
+	if event.DryRun {
+		dryRun := NewDryRun(repo, Cookstyle, cookstyleVersion, branchName, title, message, out, raisePullRequests)
+		_, canPublish := provider.(checkPublisher)
+		dryRun.CheckRun = h.Config.CheckRuns && canPublish
+		if raisePullRequests && out.Summary.OffenseCount > 0 {
+			stageRunner := buildStageCommand()
+			stageRunner.Dir = workDir
+			err = gitCmdRunner(stageRunner)
+			if err != nil {
+				h.Log.Errorf("Unable to stage changes: %v", err)
+				return Failed, nil, err
+			}
+
+			changedRunner := buildChangedFilesCommand()
+			changedRunner.Dir = workDir
+			dryRun.Paths, err = changedPaths(changedRunner)
+			if err != nil {
+				h.Log.Errorf("Unable to list staged changes: %v", err)
+				return Failed, nil, err
+			}
+
+			diffRunner := buildDiffCommand()
+			diffRunner.Dir = workDir
+			dryRun.Diff, err = gitDiff(diffRunner)
+			if err != nil {
+				h.Log.Errorf("Unable to diff changes: %v", err)
+				return Failed, nil, err
+			}
+		}
+		h.Log.Info("Dry run, not pushing, raising a PR or updating Redis")
+		h.Log.Info("Processing done!")
+		return Succeeded, &dryRun, nil
+	}
+
+	if _, ok := provider.(checkPublisher); h.Config.CheckRuns && !ok {
+		h.Log.Info("Check runs aren't supported for this repository, not publishing one")
+	} else if h.Config.CheckRuns {
+		err = publishCheckRun(ctx, provider, repo, cookstyleVersion, out)
 		if err != nil {
-			h.Log.Errorf("Unable to add new branch: %v", err)
-			return err
+			h.Log.Errorf("Unable to publish check run: %v", err)
+			return Failed, nil, err
 		}
+		h.Log.Info("Check run published")
+	}
 
+	var change *ChangeRequest
+	if !raisePullRequests {
+		h.Log.Info("Pull Requests are turned off for this repository, not raising one")
+	} else if out.Summary.OffenseCount > 0 {
 		stageRunner := buildStageCommand()
-		stageRunner.Dir = WorkingDir
+		stageRunner.Dir = workDir
 		err = gitCmdRunner(stageRunner)
 		if err != nil {
 			h.Log.Errorf("Unable to stage commit: %v", err)
-			return err
+			return Failed, nil, err
 		}
 
-		commitRunner := buildCommitCommand(os.Getenv("GIT_EMAIL"), os.Getenv("GIT_USERNAME"), title, message)
-		commitRunner.Dir = WorkingDir
+		changedRunner := buildChangedFilesCommand()
+		changedRunner.Dir = workDir
+		paths, err := changedPaths(changedRunner)
+		if err != nil {
+			h.Log.Errorf("Unable to list staged changes: %v", err)
+			return Failed, nil, err
+		}
+
+		// Offences Cookstyle can't correct leave nothing to commit
+		if len(paths) == 0 {
+			h.Log.Info("Cookstyle corrected nothing, not raising a PR")
+		} else {
+			change, err = h.raiseChangeRequest(ctx, provider, repo, event, workDir, paths, branchName, title, message)
+			if err != nil {
+				return Failed, nil, err
+			}
+		}
+	}
+
+	if _, ok := provider.(statusPublisher); h.Config.CommitStatuses && !ok {
+		h.Log.Info("Commit statuses aren't supported for this repository, not setting one")
+	} else if h.Config.CommitStatuses {
+		err = publishCommitStatus(ctx, provider, repo, cookstyleVersion, out, change)
+		if err != nil {
+			h.Log.Errorf("Unable to set commit status: %v", err)
+			return Failed, nil, err
+		}
+		h.Log.Info("Commit status set")
+	}
+
+	if _, ok := provider.(issueTracker); h.Config.TrackingIssues && !ok {
+		h.Log.Info("Tracking issues aren't supported for this repository, not updating one")
+	} else if h.Config.TrackingIssues {
+		err = h.trackOffences(ctx, provider, repo, cookstyleVersion, out)
+		if err != nil {
+			h.Log.Errorf("Unable to update tracking issue: %v", err)
+			return Failed, nil, err
+		}
+	}
+
+	// update cache with default branch sha & cookstyle version
+	err = h.Store.UpdateCommitSha(ctx, org, name, repo.LatestCommit)
+	if err != nil {
+		h.Log.Errorf("Unable to update commit sha in Redis: %v", err)
+		return Failed, nil, err
+	}
+	h.Log.Info("Redis updated with latest commit sha")
+
+	err = h.Store.UpdateToolVersion(ctx, org, name, Cookstyle, cookstyleVersion)
+	if err != nil {
+		h.Log.Errorf("Unable to update tool version in Redis: %v", err)
+		return Failed, nil, err
+	}
+	h.Log.Info("Redis updated with latest Cookstyle version")
+
+	h.Log.Info("Processing done!")
+	return Succeeded, nil, nil
+}
+
+// Commits the staged fixes to paths to branch, pushes them and opens or
+// updates the PR, closing any it supersedes
+func (h *Handler) raiseChangeRequest(ctx context.Context, provider Provider, repo Repository, event Event, workDir string, paths []string, branchName, title, message string) (*ChangeRequest, error) {
+	var err error
+	if repo.Fork != nil {
+		repo.Fork, err = forkFor(ctx, provider, repo, true)
+		if err != nil {
+			h.Log.Errorf("Unable to fork: %v", err)
+			return nil, err
+		}
+		h.Log.Infof("Pushing to the fork %s", repo.Fork.FullName())
+	}
+
+	commitWithAPI := h.Config.CommitMethod == CommitWithAPI
+	if _, ok := provider.(apiCommitter); commitWithAPI && !ok {
+		h.Log.Info("Committing through the API isn't supported for this repository, pushing with git instead")
+		commitWithAPI = false
+	}
+
+	if commitWithAPI {
+		err = commitThroughAPI(ctx, provider, repo.head(), workDir, branchName, title+"\n\n"+message)
+		if err != nil {
+			h.Log.Errorf("Unable to commit through the API: %v", err)
+			return nil, err
+		}
+	} else {
+		branchRunner := buildBranchCommand(branchName)
+		branchRunner.Dir = workDir
+		err = gitCmdRunner(branchRunner)
+		if err != nil {
+			h.Log.Errorf("Unable to add new branch: %v", err)
+			return nil, err
+		}
+
+		commitRunner := buildCommitCommand(h.Config.GitEmail, h.Config.GitUsername, title, message)
+		commitRunner.Dir = workDir
 		err = gitCmdRunner(commitRunner)
 		if err != nil {
 			h.Log.Errorf("Unable to commit: %v", err)
-			return err
+			return nil, err
 		}
 
-		pushRunner := buildPushCommand(branchName)
-		pushRunner.Dir = WorkingDir
+		remote := "origin"
+		if repo.Fork != nil {
+			remote, err = provider.CloneURL(ctx, *repo.Fork)
+			if err != nil {
+				h.Log.Errorf("Unable to get fork URL: %v", err)
+				return nil, err
+			}
+		}
+		pushRunner := buildPushCommand(remote, branchName)
+		pushRunner.Dir = workDir
 		err = gitCmdRunner(pushRunner)
 		if err != nil {
 			h.Log.Errorf("Unable to push commit: %v", err)
-			return err
+			return nil, err
 		}
+	}
 
-		// Raise a PR for that change if one does not exist
-		// put in pr body nice message based on json response from cookstyle
-		client := createClientWithAuth(ctx)
+	details := ChangeRequestDetails{
+		Title:     title,
+		Body:      message,
+		Labels:    h.Config.PullRequestLabels,
+		Assignees: h.Config.PullRequestAssignees,
+	}
+	details.Reviewers, err = reviewersFor(workDir, paths)
+	if err != nil {
+		h.Log.Errorf("Unable to find reviewers: %v", err)
+		return nil, err
+	}
 
-		opt := &github.PullRequestListOptions{Head: branchName, State: "open"}
-		existingPr, _, err := client.PullRequests.List(ctx, repo.Org, repo.Name, opt)
+	// Raise a PR for that change if one does not exist
+	// put in pr body nice message based on json response from cookstyle
+	change, err := provider.FindChangeRequest(ctx, repo, branchName)
+	if err != nil {
+		h.Log.Errorf("Unable to get PRs: %v", err)
+		return nil, err
+	}
+
+	if change == nil {
+		change, err = provider.OpenChangeRequest(ctx, repo, branchName, details)
+		err = h.reviewersWarning(err)
 		if err != nil {
-			h.Log.Errorf("Unable to get PRs: %v", err)
-			return err
+			h.Log.Errorf("Unable to create PR: %v", err)
+			return nil, err
 		}
+		h.Log.Infof("PR Raised! %s", change.URL)
+	} else {
+		// Update body as there is some change on the PR we should reflect in the text
+		err = provider.UpdateChangeRequest(ctx, repo, change, details)
+		err = h.reviewersWarning(err)
+		if err != nil {
+			h.Log.Errorf("Unable to edit PR: %v", err)
+			return nil, err
+		}
+		h.Log.Infof("PR Updated! %s", change.URL)
+	}
 
-		if len(existingPr) != 1 {
-			pr := &github.NewPullRequest{
-				Title:               &title,
-				Head:                &branchName,
-				Base:                &repo.DefaultBranch,
-				Body:                &message,
-				MaintainerCanModify: github.Bool(true),
-			}
+	method, ok := h.Config.autoMergeMethod(repo.Org, repo.Name)
+	if ok {
+		// The PR is there either way, so a repository that doesn't allow
+		// auto-merge, or a PR that can be merged already, isn't a failure
+		err = enableAutoMerge(ctx, provider, repo, change, method)
+		if err != nil {
+			h.Log.Warnf("Unable to enable auto-merge: %v", err)
+		} else {
+			h.Log.Infof("Auto-merge enabled with %s", method)
+		}
+	}
 
-			_, _, err = client.PullRequests.Create(ctx, repo.Org, repo.Name, pr)
-			if err != nil {
-				h.Log.Errorf("Unable to create PR: %v", err)
-				return err
-			}
-			h.Log.Info("PR Raised!")
-
-		} else if len(existingPr) == 1 {
-			// Update body as there is some change on the PR we should reflect in the text
-			existingPr[0].Body = &message
-			updatedPr := existingPr[0]
-			updatedPr.Body = &message
-			_, _, err = client.PullRequests.Edit(ctx, repo.Org, repo.Name, existingPr[0].GetNumber(), updatedPr)
-			if err != nil {
-				h.Log.Errorf("Unable to edit PR: %v", err)
-				return err
-			}
-			h.Log.Info("PR Updated!")
+	err = h.supersede(ctx, provider, repo, change)
+	if err != nil {
+		h.Log.Errorf("Unable to close older PRs: %v", err)
+		return nil, err
+	}
+
+	// A forced run raises the PR again, so later runs should keep it up to
+	// date, and a recreated PR closed from now on was declined after all
+	if event.Force {
+		err = h.Store.UpdateDeclinedVersion(ctx, repo.Org, repo.Name, Cookstyle, "")
+		if err != nil {
+			h.Log.Errorf("Unable to clear declined version in Redis: %v", err)
+			return nil, err
+		}
+
+		err = h.Store.UpdateRecreatedVersion(ctx, repo.Org, repo.Name, Cookstyle, "")
+		if err != nil {
+			h.Log.Errorf("Unable to clear recreated version in Redis: %v", err)
+			return nil, err
 		}
 	}
 
-	// update cache with default branch sha & cookstyle version
-	err = redis.UpdateCommitSha(ctx, org, name, repo.LatestCommit)
+	return change, nil
+}
+
+// Reports whether the PR for version was closed without merging. Once the
+// provider says so it's remembered unless dryRun, a later version can raise a
+// PR again. A PR the recreate command closed, that wasn't raised again, wasn't
+// declined.
+func (h *Handler) declined(ctx context.Context, provider Provider, repo Repository, branch, version string, dryRun bool) (bool, error) {
+	declinedVersion, err := h.Store.GetDeclinedVersion(ctx, repo.Org, repo.Name, Cookstyle)
 	if err != nil {
-		h.Log.Errorf("Unable to update commit sha in Redis: %v", err)
-		return err
+		return false, err
+	}
+	if declinedVersion == version {
+		return true, nil
+	}
+
+	declined, err := provider.ChangeRequestDeclined(ctx, repo, branch)
+	if err != nil || !declined {
+		return declined, err
 	}
-	h.Log.Info("Redis updated with latest commit sha")
 
-	err = redis.UpdateToolVersion(ctx, org, name, Cookstyle, cookstyleVersion)
+	recreatedVersion, err := h.Store.GetRecreatedVersion(ctx, repo.Org, repo.Name, Cookstyle)
+	if err != nil {
+		return false, err
+	}
+	if recreatedVersion == version {
+		return false, nil
+	}
+	if dryRun {
+		return true, nil
+	}
+
+	return true, h.Store.UpdateDeclinedVersion(ctx, repo.Org, repo.Name, Cookstyle, version)
+}
+
+// The PR is there even when its reviewers couldn't be requested, so like
+// auto-merge that only warns rather than failing every run until CODEOWNERS
+// is fixed
+func (h *Handler) reviewersWarning(err error) error {
+	if errors.Is(err, ErrReviewersNotRequested) {
+		h.Log.Warnf("Unable to request reviewers: %v", err)
+		return nil
+	}
+
+	return err
+}
+
+// Closes the PRs for older Cookstyle versions in favour of change and deletes their branches
+func (h *Handler) supersede(ctx context.Context, provider Provider, repo Repository, change *ChangeRequest) error {
+	changes, err := provider.ListChangeRequests(ctx, repo, cookstyleBranchPrefix)
 	if err != nil {
-		h.Log.Errorf("Unable to update tool version in Redis: %v", err)
 		return err
 	}
-	h.Log.Info("Redis updated with latest Cookstyle version")
 
-	h.Log.Info("Processing done!")
+	for _, older := range changes {
+		if older.Number == change.Number {
+			continue
+		}
+
+		comment := fmt.Sprintf("Superseded by %s, which has the fixes for a newer Cookstyle version.", change.URL)
+		err = provider.CloseChangeRequest(ctx, repo, older, comment)
+		if err != nil {
+			return err
+		}
+		h.Log.Infof("PR Closed! %s", older.URL)
+	}
+
 	return nil
 }
@@ -1 +1,566 @@
 package analyser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v39/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/youshy/logger"
+)
+
+// Lists and closes change requests and reports them declined, anything else it isn't asked for panics
+type fakeProvider struct {
+	Provider
+	open   []*ChangeRequest
+	closed map[int]string
+	// Whether the latest change request was closed without merging, and how often it was asked
+	declined bool
+	asked    int
+}
+
+func (f *fakeProvider) ListChangeRequests(ctx context.Context, repo Repository, prefix string) ([]*ChangeRequest, error) {
+	return f.open, nil
+}
+
+func (f *fakeProvider) CloseChangeRequest(ctx context.Context, repo Repository, change *ChangeRequest, comment string) error {
+	f.closed[change.Number] = comment
+	return nil
+}
+
+func (f *fakeProvider) ChangeRequestDeclined(ctx context.Context, repo Repository, branch string) (bool, error) {
+	f.asked++
+	return f.declined, nil
+}
+
+// Keeps the declined and recreated versions, anything else it isn't asked for panics
+type fakeDeclinedStore struct {
+	KeyValueStore
+	versions map[string]string
+}
+
+func (f *fakeDeclinedStore) GetDeclinedVersion(ctx context.Context, org, name, tool string) (string, error) {
+	return f.versions[org+"/"+name+"/"+tool], nil
+}
+
+func (f *fakeDeclinedStore) UpdateDeclinedVersion(ctx context.Context, org, name, tool, version string) error {
+	f.versions[org+"/"+name+"/"+tool] = version
+	return nil
+}
+
+func (f *fakeDeclinedStore) GetRecreatedVersion(ctx context.Context, org, name, tool string) (string, error) {
+	return f.versions["recreated/"+org+"/"+name+"/"+tool], nil
+}
+
+func (f *fakeDeclinedStore) UpdateRecreatedVersion(ctx context.Context, org, name, tool, version string) error {
+	f.versions["recreated/"+org+"/"+name+"/"+tool] = version
+	return nil
+}
+
+func TestHandlerDeclined(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRepo("stylelia", "snort", "main")
+
+	t.Run("Remembers a PR closed without merging", func(t *testing.T) {
+		store := &fakeDeclinedStore{versions: make(map[string]string)}
+		handler := Handler{Log: logger.NewLogger(logger.DEBUG, false), Store: store}
+		provider := &fakeProvider{declined: true}
+
+		declined, err := handler.declined(ctx, provider, repo, "stylelia/cookstyle_7.25.6", "7.25.6", false)
+		assert.NoError(t, err)
+		assert.True(t, declined)
+		assert.Equal(t, "7.25.6", store.versions["stylelia/snort/Cookstyle"])
+
+		declined, err = handler.declined(ctx, provider, repo, "stylelia/cookstyle_7.25.6", "7.25.6", false)
+		assert.NoError(t, err)
+		assert.True(t, declined)
+		assert.Equal(t, 1, provider.asked)
+	})
+
+	t.Run("Dry runs don't remember anything", func(t *testing.T) {
+		store := &fakeDeclinedStore{versions: make(map[string]string)}
+		handler := Handler{Log: logger.NewLogger(logger.DEBUG, false), Store: store}
+
+		declined, err := handler.declined(ctx, &fakeProvider{declined: true}, repo, "stylelia/cookstyle_7.25.6", "7.25.6", true)
+		assert.NoError(t, err)
+		assert.True(t, declined)
+		assert.Empty(t, store.versions)
+	})
+
+	t.Run("A PR the recreate command closed wasn't declined", func(t *testing.T) {
+		store := &fakeDeclinedStore{versions: map[string]string{"recreated/stylelia/snort/Cookstyle": "7.25.6"}}
+		handler := Handler{Log: logger.NewLogger(logger.DEBUG, false), Store: store}
+
+		declined, err := handler.declined(ctx, &fakeProvider{declined: true}, repo, "stylelia/cookstyle_7.25.6", "7.25.6", false)
+		assert.NoError(t, err)
+		assert.False(t, declined)
+		assert.Empty(t, store.versions["stylelia/snort/Cookstyle"])
+	})
+
+	t.Run("A later version can raise a PR again", func(t *testing.T) {
+		store := &fakeDeclinedStore{versions: map[string]string{"stylelia/snort/Cookstyle": "7.25.6"}}
+		handler := Handler{Log: logger.NewLogger(logger.DEBUG, false), Store: store}
+
+		declined, err := handler.declined(ctx, &fakeProvider{}, repo, "stylelia/cookstyle_7.26.0", "7.26.0", false)
+		assert.NoError(t, err)
+		assert.False(t, declined)
+		assert.Equal(t, "7.25.6", store.versions["stylelia/snort/Cookstyle"])
+	})
+}
+
+func TestHandlerSupersede(t *testing.T) {
+	handler := Handler{Log: logger.NewLogger(logger.DEBUG, false)}
+	latest := &ChangeRequest{Number: 5, URL: "https://github.com/stylelia/snort/pull/5", Branch: "stylelia/cookstyle_7.26.0"}
+
+	t.Run("Closes the older Pull Requests linking the latest", func(t *testing.T) {
+		provider := &fakeProvider{
+			open: []*ChangeRequest{
+				{Number: 3, Branch: "stylelia/cookstyle_7.25.0"},
+				{Number: 5, Branch: "stylelia/cookstyle_7.26.0"},
+				{Number: 4, Branch: "stylelia/cookstyle_7.25.6"},
+			},
+			closed: make(map[int]string),
+		}
+
+		err := handler.supersede(context.Background(), provider, NewRepo("stylelia", "snort", "main"), latest)
+		assert.NoError(t, err)
+
+		comment := "Superseded by https://github.com/stylelia/snort/pull/5, which has the fixes for a newer Cookstyle version."
+		assert.Equal(t, map[int]string{3: comment, 4: comment}, provider.closed)
+	})
+
+	t.Run("Leaves the only Pull Request open", func(t *testing.T) {
+		provider := &fakeProvider{open: []*ChangeRequest{latest}, closed: make(map[int]string)}
+
+		err := handler.supersede(context.Background(), provider, NewRepo("stylelia", "snort", "main"), latest)
+		assert.NoError(t, err)
+		assert.Empty(t, provider.closed)
+	})
+}
+
+// Keeps what a run caches besides the declined versions
+type fakeRunStore struct {
+	*fakeDeclinedStore
+	commits map[string]string
+	tools   map[string]string
+	issues  map[string]int
+}
+
+func newFakeRunStore() *fakeRunStore {
+	return &fakeRunStore{
+		fakeDeclinedStore: &fakeDeclinedStore{versions: make(map[string]string)},
+		commits:           make(map[string]string),
+		tools:             make(map[string]string),
+		issues:            make(map[string]int),
+	}
+}
+
+func (f *fakeRunStore) GetCommitSha(ctx context.Context, org, name string) (string, error) {
+	return f.commits[org+"/"+name], nil
+}
+
+func (f *fakeRunStore) UpdateCommitSha(ctx context.Context, org, name, sha string) error {
+	f.commits[org+"/"+name] = sha
+	return nil
+}
+
+func (f *fakeRunStore) GetToolVersion(ctx context.Context, org, name, tool string) (string, error) {
+	return f.tools[org+"/"+name+"/"+tool], nil
+}
+
+func (f *fakeRunStore) UpdateToolVersion(ctx context.Context, org, name, tool, version string) error {
+	f.tools[org+"/"+name+"/"+tool] = version
+	return nil
+}
+
+func (f *fakeRunStore) GetIgnoredCops(ctx context.Context, org, name, tool string) ([]string, error) {
+	return nil, nil
+}
+
+func (f *fakeRunStore) GetTrackingIssue(ctx context.Context, org, name, tool string) (int, error) {
+	return f.issues[org+"/"+name+"/"+tool], nil
+}
+
+func (f *fakeRunStore) UpdateTrackingIssue(ctx context.Context, org, name, tool string, number int) error {
+	f.issues[org+"/"+name+"/"+tool] = number
+	return nil
+}
+
+// A repository cloned from, and pushed to, a local directory. PRs it's asked
+// to open are recorded, there are never any to find.
+type fakeCloneProvider struct {
+	fakeProvider
+	source string
+	opened []ChangeRequestDetails
+}
+
+func (f *fakeCloneProvider) DefaultBranch(ctx context.Context, repo Repository) (string, error) {
+	return "main", nil
+}
+
+func (f *fakeCloneProvider) LatestCommit(ctx context.Context, repo Repository) (string, error) {
+	return "b64d5bae3cee6da8c305c0f46f678914cb22e483", nil
+}
+
+func (f *fakeCloneProvider) CloneURL(ctx context.Context, repo Repository) (string, error) {
+	return f.source, nil
+}
+
+func (f *fakeCloneProvider) FindChangeRequest(ctx context.Context, repo Repository, branch string) (*ChangeRequest, error) {
+	return nil, nil
+}
+
+func (f *fakeCloneProvider) OpenChangeRequest(ctx context.Context, repo Repository, branch string, details ChangeRequestDetails) (*ChangeRequest, error) {
+	f.opened = append(f.opened, details)
+	return &ChangeRequest{Number: 5, URL: "https://github.com/stylelia/snort/pull/5", Branch: branch}, nil
+}
+
+// Opens PRs but can't request their reviewers
+type fakeReviewersProvider struct {
+	*fakeCloneProvider
+}
+
+func (f *fakeReviewersProvider) OpenChangeRequest(ctx context.Context, repo Repository, branch string, details ChangeRequestDetails) (*ChangeRequest, error) {
+	change, _ := f.fakeCloneProvider.OpenChangeRequest(ctx, repo, branch, details)
+	return change, fmt.Errorf("%w: reviewer is not a collaborator", ErrReviewersNotRequested)
+}
+
+// Records the check runs published
+type fakeCheckPublisher struct {
+	*fakeCloneProvider
+	published []CookstyleCheck
+}
+
+func (f *fakeCheckPublisher) PublishCheckRun(ctx context.Context, repo Repository, cookstyleVersion string, result CookstyleCheck) error {
+	f.published = append(f.published, result)
+	return nil
+}
+
+// Records the commit statuses set
+type fakeStatusPublisher struct {
+	*fakeCloneProvider
+	statuses []*github.RepoStatus
+}
+
+func (f *fakeStatusPublisher) SetCommitStatus(ctx context.Context, repo Repository, status *github.RepoStatus) error {
+	f.statuses = append(f.statuses, status)
+	return nil
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// Creates a cookbook repository to clone and puts a cookstyle on the PATH
+// that runs fix in the clone, prints result and exits like Cookstyle does
+func newTestCookbook(t *testing.T, fix string, result CookstyleCheck) string {
+	source := t.TempDir()
+	git := func(args ...string) {
+		command := exec.Command("git", append([]string{"-c", "user.name=Stylelia", "-c", "user.email=stylelia@example.com"}, args...)...)
+		command.Dir = source
+		output, err := command.CombinedOutput()
+		assert.NoError(t, err, string(output))
+	}
+	git("init", "-q", "-b", "main")
+	assert.NoError(t, os.WriteFile(filepath.Join(source, "metadata.rb"), []byte("name 'snort'\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(source, "CODEOWNERS"), []byte("*.rb @xorima\n"), 0644))
+	git("add", "-A")
+	git("commit", "-q", "-m", "Initial commit")
+	// Pushes to the checked out branch are refused, Stylelia pushes to another anyway
+	git("checkout", "-q", "--detach")
+
+	output, err := json.Marshal(result)
+	assert.NoError(t, err)
+	exitCode := 0
+	for _, file := range result.Files {
+		for _, offense := range file.Offenses {
+			if !offense.Corrected {
+				exitCode = 1
+			}
+		}
+	}
+
+	bin := t.TempDir()
+	script := "#!/bin/sh\n" + fix + "\ncat <<'EOF'\n" + string(output) + "\nEOF\nexit " + string(rune('0'+exitCode)) + "\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(bin, "cookstyle"), []byte(script), 0755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	return source
+}
+
+// A handler running against provider, with Cookstyle 7.25.6 as the latest release
+func newTestRunHandler(t *testing.T, provider Provider, store KeyValueStore, config Config) Handler {
+	rubygems := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"version": "7.25.6"}`))}, nil
+	})
+
+	config.GitEmail = "stylelia@example.com"
+	config.GitUsername = "Stylelia"
+	return Handler{
+		Client:     &http.Client{Transport: rubygems},
+		Log:        logger.NewLogger(logger.DEBUG, false),
+		Config:     config,
+		Store:      store,
+		WorkingDir: t.TempDir(),
+		providerFor: func(ctx context.Context, org, name string) (Provider, error) {
+			return provider, nil
+		},
+	}
+}
+
+var correctedOffense = Offenses{CopName: "Chef/Style/CommentFormat", Message: "Properly format header comments", Corrected: true, Location: Location{StartLine: 1}}
+
+var uncorrectedOffense = Offenses{CopName: "Chef/Deprecations/NodeSet", Message: "Do not use node.set", Location: Location{StartLine: 2}}
+
+func TestHandlerAnalyse(t *testing.T) {
+	ctx := context.Background()
+	event := Event{Organisation: "stylelia", Name: "snort"}
+
+	t.Run("Raises a PR for the offences Cookstyle corrected", func(t *testing.T) {
+		result := CookstyleCheck{
+			Files:   []Files{{Path: "metadata.rb", Offenses: []Offenses{correctedOffense}}},
+			Summary: Summary{OffenseCount: 1},
+		}
+		provider := &fakeCloneProvider{fakeProvider: fakeProvider{closed: make(map[int]string)}}
+		provider.source = newTestCookbook(t, "echo '# Fixed' >> metadata.rb", result)
+		store := newFakeRunStore()
+		handler := newTestRunHandler(t, provider, store, Config{})
+
+		outcome, _, err := handler.analyse(ctx, event)
+		assert.NoError(t, err)
+		assert.Equal(t, Succeeded, outcome)
+		assert.Len(t, provider.opened, 1)
+		assert.Equal(t, "Stylelia: Cookstyle 7.25.6 updates", provider.opened[0].Title)
+		// CODEOWNERS gives *.rb to xorima, who owns the fixed metadata.rb
+		assert.Equal(t, []string{"xorima"}, provider.opened[0].Reviewers)
+		assert.Equal(t, "7.25.6", store.tools["stylelia/snort/Cookstyle"])
+	})
+
+	t.Run("Pushes to the repository when forks are on but the provider can't fork", func(t *testing.T) {
+		for _, provider := range []Provider{&gitlabProvider{}, &giteaProvider{}} {
+			_, ok := provider.(forker)
+			assert.False(t, ok)
+		}
+
+		result := CookstyleCheck{
+			Files:   []Files{{Path: "metadata.rb", Offenses: []Offenses{correctedOffense}}},
+			Summary: Summary{OffenseCount: 1},
+		}
+		// Can't fork either, like GitLab and Gitea
+		provider := &fakeCloneProvider{fakeProvider: fakeProvider{closed: make(map[int]string)}}
+		provider.source = newTestCookbook(t, "echo '# Fixed' >> metadata.rb", result)
+		store := newFakeRunStore()
+		handler := newTestRunHandler(t, provider, store, Config{Forks: true})
+
+		outcome, _, err := handler.analyse(ctx, event)
+		assert.NoError(t, err)
+		assert.Equal(t, Succeeded, outcome)
+		assert.Len(t, provider.opened, 1)
+
+		branches, err := exec.Command("git", "-C", provider.source, "branch", "--list", "stylelia/cookstyle_7.25.6").Output()
+		assert.NoError(t, err)
+		assert.Contains(t, string(branches), "stylelia/cookstyle_7.25.6")
+	})
+
+	t.Run("Pushes with git when the provider can't commit through the API", func(t *testing.T) {
+		result := CookstyleCheck{
+			Files:   []Files{{Path: "metadata.rb", Offenses: []Offenses{correctedOffense}}},
+			Summary: Summary{OffenseCount: 1},
+		}
+		provider := &fakeCloneProvider{fakeProvider: fakeProvider{closed: make(map[int]string)}}
+		provider.source = newTestCookbook(t, "echo '# Fixed' >> metadata.rb", result)
+		store := newFakeRunStore()
+		handler := newTestRunHandler(t, provider, store, Config{CommitMethod: CommitWithAPI})
+
+		outcome, _, err := handler.analyse(ctx, event)
+		assert.NoError(t, err)
+		assert.Equal(t, Succeeded, outcome)
+		assert.Len(t, provider.opened, 1)
+
+		branches, err := exec.Command("git", "-C", provider.source, "branch", "--list", "stylelia/cookstyle_7.25.6").Output()
+		assert.NoError(t, err)
+		assert.Contains(t, string(branches), "stylelia/cookstyle_7.25.6")
+	})
+
+	t.Run("Only warns when the PR's reviewers can't be requested", func(t *testing.T) {
+		result := CookstyleCheck{
+			Files:   []Files{{Path: "metadata.rb", Offenses: []Offenses{correctedOffense}}},
+			Summary: Summary{OffenseCount: 1},
+		}
+		provider := &fakeCloneProvider{fakeProvider: fakeProvider{closed: make(map[int]string)}}
+		provider.source = newTestCookbook(t, "echo '# Fixed' >> metadata.rb", result)
+		store := newFakeRunStore()
+		handler := newTestRunHandler(t, &fakeReviewersProvider{fakeCloneProvider: provider}, store, Config{})
+
+		outcome, _, err := handler.analyse(ctx, event)
+		assert.NoError(t, err)
+		assert.Equal(t, Succeeded, outcome)
+		assert.Len(t, provider.opened, 1)
+		assert.Equal(t, "b64d5bae3cee6da8c305c0f46f678914cb22e483", store.commits["stylelia/snort"])
+	})
+
+	t.Run("Only tracks offences Cookstyle couldn't correct", func(t *testing.T) {
+		result := CookstyleCheck{
+			Files:   []Files{{Path: "metadata.rb", Offenses: []Offenses{uncorrectedOffense}}},
+			Summary: Summary{OffenseCount: 1},
+		}
+		provider := &fakeCloneProvider{}
+		provider.source = newTestCookbook(t, "", result)
+		tracker := &fakeIssueTracker{Provider: provider}
+		store := newFakeRunStore()
+		handler := newTestRunHandler(t, tracker, store, Config{TrackingIssues: true})
+
+		outcome, _, err := handler.analyse(ctx, event)
+		assert.NoError(t, err)
+		assert.Equal(t, Succeeded, outcome)
+		assert.Empty(t, provider.opened)
+		assert.Equal(t, trackingIssueBody("7.25.6", result), tracker.opened)
+		assert.Equal(t, "b64d5bae3cee6da8c305c0f46f678914cb22e483", store.commits["stylelia/snort"])
+	})
+
+	t.Run("Only publishes the check run when Pull Requests are turned off", func(t *testing.T) {
+		result := CookstyleCheck{
+			Files:   []Files{{Path: "metadata.rb", Offenses: []Offenses{correctedOffense}}},
+			Summary: Summary{OffenseCount: 1},
+		}
+		provider := &fakeCloneProvider{}
+		provider.source = newTestCookbook(t, "echo '# Fixed' >> metadata.rb", result)
+		publisher := &fakeCheckPublisher{fakeCloneProvider: provider}
+		store := newFakeRunStore()
+		config := Config{CheckRuns: true, PullRequests: map[string]bool{"stylelia/snort": false}}
+		handler := newTestRunHandler(t, publisher, store, config)
+
+		outcome, _, err := handler.analyse(ctx, event)
+		assert.NoError(t, err)
+		assert.Equal(t, Succeeded, outcome)
+		assert.Equal(t, []CookstyleCheck{result}, publisher.published)
+		assert.Empty(t, provider.opened)
+
+		branches, err := exec.Command("git", "-C", provider.source, "branch", "--list", cookstyleBranchPrefix+"*").Output()
+		assert.NoError(t, err)
+		assert.Empty(t, string(branches))
+		assert.Equal(t, "b64d5bae3cee6da8c305c0f46f678914cb22e483", store.commits["stylelia/snort"])
+	})
+
+	t.Run("Raises the PR when check runs are on but the provider can't publish them", func(t *testing.T) {
+		result := CookstyleCheck{
+			Files:   []Files{{Path: "metadata.rb", Offenses: []Offenses{correctedOffense}}},
+			Summary: Summary{OffenseCount: 1},
+		}
+		// Can't publish check runs either, like GitLab and Gitea
+		provider := &fakeCloneProvider{fakeProvider: fakeProvider{closed: make(map[int]string)}}
+		provider.source = newTestCookbook(t, "echo '# Fixed' >> metadata.rb", result)
+		store := newFakeRunStore()
+		handler := newTestRunHandler(t, provider, store, Config{CheckRuns: true})
+
+		outcome, _, err := handler.analyse(ctx, event)
+		assert.NoError(t, err)
+		assert.Equal(t, Succeeded, outcome)
+		assert.Len(t, provider.opened, 1)
+		assert.Equal(t, "b64d5bae3cee6da8c305c0f46f678914cb22e483", store.commits["stylelia/snort"])
+	})
+
+	t.Run("Dry runs report the check run instead of a PR when Pull Requests are turned off", func(t *testing.T) {
+		result := CookstyleCheck{
+			Files:   []Files{{Path: "metadata.rb", Offenses: []Offenses{correctedOffense}}},
+			Summary: Summary{OffenseCount: 1},
+		}
+		provider := &fakeCloneProvider{}
+		provider.source = newTestCookbook(t, "echo '# Fixed' >> metadata.rb", result)
+		publisher := &fakeCheckPublisher{fakeCloneProvider: provider}
+		handler := newTestRunHandler(t, publisher, newFakeRunStore(), Config{CheckRuns: true, SkipPullRequests: true})
+
+		outcome, dryRun, err := handler.analyse(ctx, Event{Organisation: "stylelia", Name: "snort", DryRun: true})
+		assert.NoError(t, err)
+		assert.Equal(t, Succeeded, outcome)
+		assert.Empty(t, publisher.published)
+		assert.True(t, dryRun.CheckRun)
+		assert.False(t, dryRun.PullRequest)
+		assert.Contains(t, dryRun.Summary(), "Would publish the \"Stylelia Cookstyle\" check run")
+		assert.NotContains(t, dryRun.Summary(), "Would push")
+	})
+
+	t.Run("Caches the run when commit statuses are on but the provider can't set them", func(t *testing.T) {
+		result := CookstyleCheck{
+			Files:   []Files{{Path: "metadata.rb", Offenses: []Offenses{correctedOffense}}},
+			Summary: Summary{OffenseCount: 1},
+		}
+		// Can't set commit statuses either, like GitLab and Gitea
+		provider := &fakeCloneProvider{fakeProvider: fakeProvider{closed: make(map[int]string)}}
+		provider.source = newTestCookbook(t, "echo '# Fixed' >> metadata.rb", result)
+		store := newFakeRunStore()
+		handler := newTestRunHandler(t, provider, store, Config{CommitStatuses: true})
+
+		outcome, _, err := handler.analyse(ctx, event)
+		assert.NoError(t, err)
+		assert.Equal(t, Succeeded, outcome)
+		assert.Len(t, provider.opened, 1)
+		assert.Equal(t, "b64d5bae3cee6da8c305c0f46f678914cb22e483", store.commits["stylelia/snort"])
+		assert.Equal(t, "7.25.6", store.tools["stylelia/snort/Cookstyle"])
+	})
+
+	t.Run("Caches the run when tracking issues are on but the provider can't keep them", func(t *testing.T) {
+		result := CookstyleCheck{
+			Files:   []Files{{Path: "metadata.rb", Offenses: []Offenses{uncorrectedOffense}}},
+			Summary: Summary{OffenseCount: 1},
+		}
+		// Can't keep tracking issues either, like GitLab and Gitea
+		provider := &fakeCloneProvider{}
+		provider.source = newTestCookbook(t, "", result)
+		store := newFakeRunStore()
+		handler := newTestRunHandler(t, provider, store, Config{TrackingIssues: true})
+
+		outcome, _, err := handler.analyse(ctx, event)
+		assert.NoError(t, err)
+		assert.Equal(t, Succeeded, outcome)
+		assert.Equal(t, "b64d5bae3cee6da8c305c0f46f678914cb22e483", store.commits["stylelia/snort"])
+		assert.Equal(t, "7.25.6", store.tools["stylelia/snort/Cookstyle"])
+	})
+
+	t.Run("Dry runs don't raise a PR when Cookstyle corrected nothing", func(t *testing.T) {
+		result := CookstyleCheck{
+			Files:   []Files{{Path: "metadata.rb", Offenses: []Offenses{uncorrectedOffense}}},
+			Summary: Summary{OffenseCount: 1},
+		}
+		provider := &fakeCloneProvider{}
+		provider.source = newTestCookbook(t, "", result)
+		handler := newTestRunHandler(t, provider, newFakeRunStore(), Config{})
+
+		outcome, dryRun, err := handler.analyse(ctx, Event{Organisation: "stylelia", Name: "snort", DryRun: true})
+		assert.NoError(t, err)
+		assert.Equal(t, Succeeded, outcome)
+		assert.Equal(t, "stylelia/snort", dryRun.Name)
+		assert.Empty(t, dryRun.Paths)
+		assert.Contains(t, dryRun.Summary(), "Cookstyle corrected nothing, would not raise a PR")
+		assert.NotContains(t, dryRun.Summary(), "Would push")
+	})
+
+	t.Run("Sets a commit status instead of raising a PR when Pull Requests are skipped", func(t *testing.T) {
+		result := CookstyleCheck{
+			Files:   []Files{{Path: "metadata.rb", Offenses: []Offenses{correctedOffense}}},
+			Summary: Summary{OffenseCount: 1},
+		}
+		provider := &fakeCloneProvider{}
+		provider.source = newTestCookbook(t, "echo '# Fixed' >> metadata.rb", result)
+		publisher := &fakeStatusPublisher{fakeCloneProvider: provider}
+		store := newFakeRunStore()
+		handler := newTestRunHandler(t, publisher, store, Config{CommitStatuses: true, SkipPullRequests: true})
+
+		outcome, _, err := handler.analyse(ctx, event)
+		assert.NoError(t, err)
+		assert.Equal(t, Succeeded, outcome)
+		assert.Empty(t, provider.opened)
+		assert.Equal(t, []*github.RepoStatus{commitStatus("7.25.6", result, nil)}, publisher.statuses)
+		assert.Equal(t, "failure", publisher.statuses[0].GetState())
+	})
+}
@@ -0,0 +1,61 @@
+package analyser
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/go-github/v39/github"
+)
+
+const defaultCookbookMarker string = "metadata.rb"
+
+// Pages through every repository in the organisation and returns the names
+// of those with the marker file at their root. Archived repositories are
+// read only so they are left out.
+func listCookbookRepos(ctx context.Context, client *github.Client, org, marker string) ([]string, error) {
+	var names []string
+
+	opt := &github.RepositoryListByOrgOptions{
+		Type:        "all",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		repos, response, err := client.Repositories.ListByOrg(ctx, org, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, repo := range repos {
+			if repo.GetArchived() {
+				continue
+			}
+
+			ok, err := hasMarker(ctx, client, org, repo.GetName(), marker)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				names = append(names, repo.GetName())
+			}
+		}
+
+		if response.NextPage == 0 {
+			break
+		}
+		opt.Page = response.NextPage
+	}
+
+	return names, nil
+}
+
+func hasMarker(ctx context.Context, client *github.Client, org, name, marker string) (bool, error) {
+	_, _, response, err := client.Repositories.GetContents(ctx, org, name, marker, nil)
+	if response != nil && response.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,91 @@
+package analyser
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v39/github"
+	"github.com/stretchr/testify/assert"
+)
+
+// Points a go-github client at a test server
+func newTestGithubClient(server *httptest.Server) *github.Client {
+	client := github.NewClient(nil)
+	baseURL, err := url.Parse(server.URL + "/")
+	if err != nil {
+		// we should never panic here.
+		panic(err)
+	}
+	client.BaseURL = baseURL
+	return client
+}
+
+func TestListCookbookRepos(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/stylelia/repos", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `[{"name": "nginx"}, {"name": "old-cookbook", "archived": true}]`)
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<%s/orgs/stylelia/repos?page=2>; rel="next"`, "http://"+r.Host))
+		fmt.Fprint(w, `[{"name": "snort"}, {"name": "analyser"}]`)
+	})
+	for _, cookbook := range []string{"snort", "nginx", "old-cookbook"} {
+		mux.HandleFunc(fmt.Sprintf("/repos/stylelia/%s/contents/metadata.rb", cookbook), func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"type": "file", "name": "metadata.rb", "path": "metadata.rb"}`)
+		})
+	}
+	mux.HandleFunc("/repos/stylelia/analyser/contents/metadata.rb", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := newTestGithubClient(server)
+
+	t.Run("Returns every unarchived repository with the marker across all pages", func(t *testing.T) {
+		names, err := listCookbookRepos(context.Background(), client, "stylelia", "metadata.rb")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"snort", "nginx"}, names)
+	})
+
+	t.Run("Returns an error when the organisation can't be listed", func(t *testing.T) {
+		_, err := listCookbookRepos(context.Background(), client, "empire", "metadata.rb")
+		assert.Error(t, err)
+	})
+}
+
+func TestHasMarker(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/stylelia/snort/contents/metadata.rb", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"type": "file", "name": "metadata.rb", "path": "metadata.rb"}`)
+	})
+	mux.HandleFunc("/repos/stylelia/snort/contents/Berksfile", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+	})
+	mux.HandleFunc("/repos/stylelia/snort/contents/Policyfile.rb", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "Server Error"}`, http.StatusInternalServerError)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := newTestGithubClient(server)
+
+	ok, err := hasMarker(context.Background(), client, "stylelia", "snort", "metadata.rb")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = hasMarker(context.Background(), client, "stylelia", "snort", "Berksfile")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = hasMarker(context.Background(), client, "stylelia", "snort", "Policyfile.rb")
+	assert.Error(t, err)
+}
@@ -0,0 +1,163 @@
+package analyser
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const defaultConcurrency int = 4
+
+// What happened to a single repository
+type Outcome string
+
+const (
+	Succeeded Outcome = "succeeded"
+	Skipped   Outcome = "skipped"
+	Failed    Outcome = "failed"
+)
+
+// Aggregated result of a run across one or more repositories
+type Result struct {
+	Succeeded []string     `json:"succeeded"`
+	Skipped   []string     `json:"skipped"`
+	Failed    []FailedRepo `json:"failed"`
+	DryRuns   []DryRun     `json:"dry_runs,omitempty"`
+}
+
+type FailedRepo struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+func (r *Result) add(name string, outcome Outcome, dryRun *DryRun, err error) {
+	if dryRun != nil {
+		r.DryRuns = append(r.DryRuns, *dryRun)
+	}
+
+	switch outcome {
+	case Succeeded:
+		r.Succeeded = append(r.Succeeded, name)
+	case Skipped:
+		r.Skipped = append(r.Skipped, name)
+	default:
+		r.Failed = append(r.Failed, FailedRepo{Name: name, Error: err.Error()})
+	}
+}
+
+// Sorts each list so the result doesn't depend on which worker finished first
+func (r *Result) sort() {
+	sort.Strings(r.Succeeded)
+	sort.Strings(r.Skipped)
+	sort.Slice(r.Failed, func(i, j int) bool { return r.Failed[i].Name < r.Failed[j].Name })
+	sort.Slice(r.DryRuns, func(i, j int) bool { return r.DryRuns[i].Name < r.DryRuns[j].Name })
+}
+
+// Returns an error naming every failed repository, or nil if none failed
+func (r Result) err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+
+	failed := make([]string, 0, len(r.Failed))
+	for _, f := range r.Failed {
+		failed = append(failed, fmt.Sprintf("%s (%s)", f.Name, f.Error))
+	}
+	total := len(r.Succeeded) + len(r.Skipped) + len(r.Failed)
+
+	return fmt.Errorf("%d of %d repositories failed: %s", len(r.Failed), total, strings.Join(failed, ", "))
+}
+
+// Human readable summary of the result
+func (r Result) Summary() string {
+	var b strings.Builder
+	for _, d := range r.DryRuns {
+		b.WriteString(d.Summary())
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "Succeeded (%d): %s\n", len(r.Succeeded), strings.Join(r.Succeeded, ", "))
+	fmt.Fprintf(&b, "Skipped (%d): %s\n", len(r.Skipped), strings.Join(r.Skipped, ", "))
+	fmt.Fprintf(&b, "Failed (%d):\n", len(r.Failed))
+	for _, f := range r.Failed {
+		fmt.Fprintf(&b, "  %s: %s\n", f.Name, f.Error)
+	}
+
+	return b.String()
+}
+
+// Analyses the repositories, event.Concurrency at a time. Every repository
+// is cloned into its own workspace and a failing one never stops the others.
+func (h *Handler) runPool(ctx context.Context, event Event, repos []Repository) Result {
+	workers := event.Concurrency
+	if workers > len(repos) {
+		workers = len(repos)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan Repository)
+	var mu sync.Mutex
+	var result Result
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for repo := range jobs {
+				outcome, dryRun, err := h.analyseIsolated(ctx, event, repo)
+				mu.Lock()
+				result.add(repo.FullName(), outcome, dryRun, err)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, repo := range repos {
+		jobs <- repo
+	}
+	close(jobs)
+	wg.Wait()
+
+	result.sort()
+	return result
+}
+
+// Analyses a single repository in a fresh workspace
+func (h *Handler) analyseIsolated(ctx context.Context, event Event, repo Repository) (outcome Outcome, dryRun *DryRun, err error) {
+	// A panic is just another failed repository
+	defer func() {
+		if r := recover(); r != nil {
+			outcome, dryRun, err = Failed, nil, fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	workspace, err := os.MkdirTemp(h.WorkingDir, "stylelia-")
+	if err != nil {
+		return Failed, nil, err
+	}
+	defer os.RemoveAll(workspace)
+
+	worker := *h
+	worker.WorkingDir = workspace
+	worker.Log = h.Log.With("repository", repo.FullName())
+
+	repoEvent := event
+	repoEvent.Organisation = repo.Org
+	repoEvent.Name = repo.Name
+	analyse := (*Handler).analyse
+	if h.analyseRepo != nil {
+		analyse = h.analyseRepo
+	}
+	outcome, dryRun, err = analyse(&worker, ctx, repoEvent)
+	if err != nil {
+		worker.Log.Errorf("Unable to analyse: %v", err)
+		return Failed, nil, err
+	}
+
+	return outcome, dryRun, nil
+}
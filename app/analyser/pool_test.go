@@ -0,0 +1,115 @@
+package analyser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/youshy/logger"
+)
+
+func TestRunPool(t *testing.T) {
+	var running, maxRunning int32
+	var mu sync.Mutex
+	workspaces := make(map[string]string)
+
+	analyseRepo := func(h *Handler, ctx context.Context, event Event) (Outcome, *DryRun, error) {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			previous := atomic.LoadInt32(&maxRunning)
+			if current <= previous || atomic.CompareAndSwapInt32(&maxRunning, previous, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		workspaces[event.Name] = h.WorkingDir
+		mu.Unlock()
+
+		switch event.Name {
+		case "upToDate":
+			return Skipped, nil, nil
+		case "broken":
+			return Failed, nil, errors.New("clone failed")
+		case "panics":
+			panic("cookstyle exploded")
+		case "dryRun":
+			return Succeeded, &DryRun{Name: event.Name}, nil
+		}
+		return Succeeded, nil, nil
+	}
+
+	handler := NewHandler(nil, logger.NewLogger(logger.DEBUG, false), Config{})
+	handler.WorkingDir = t.TempDir()
+	handler.analyseRepo = analyseRepo
+
+	var repos []Repository
+	for i := 0; i < 8; i++ {
+		repos = append(repos, NewRepo("stylelia", fmt.Sprintf("cookbook%d", i), ""))
+	}
+	for _, name := range []string{"upToDate", "broken", "panics", "dryRun"} {
+		repos = append(repos, NewRepo("stylelia", name, ""))
+	}
+
+	event := Event{Concurrency: 3}
+	result := handler.runPool(context.Background(), event, repos)
+
+	t.Run("Every repository ends up in the result", func(t *testing.T) {
+		assert.Len(t, result.Succeeded, 9)
+		assert.Equal(t, []string{"stylelia/upToDate"}, result.Skipped)
+		assert.Equal(t, []FailedRepo{
+			{Name: "stylelia/broken", Error: "clone failed"},
+			{Name: "stylelia/panics", Error: "panic: cookstyle exploded"},
+		}, result.Failed)
+	})
+
+	t.Run("Dry runs are reported", func(t *testing.T) {
+		assert.Equal(t, []DryRun{{Name: "dryRun"}}, result.DryRuns)
+	})
+
+	t.Run("No more than the configured number of repositories run at once", func(t *testing.T) {
+		assert.LessOrEqual(t, maxRunning, int32(3))
+	})
+
+	t.Run("Every repository gets its own workspace", func(t *testing.T) {
+		seen := make(map[string]bool)
+		for _, workspace := range workspaces {
+			assert.False(t, seen[workspace])
+			seen[workspace] = true
+			assert.NoDirExists(t, workspace)
+		}
+		assert.Len(t, seen, len(repos))
+	})
+}
+
+func TestResultErr(t *testing.T) {
+	t.Run("No failures is not an error", func(t *testing.T) {
+		result := Result{Succeeded: []string{"snort"}, Skipped: []string{"nginx"}}
+		assert.NoError(t, result.err())
+	})
+
+	t.Run("Failures are listed in the error", func(t *testing.T) {
+		result := Result{
+			Succeeded: []string{"snort"},
+			Failed:    []FailedRepo{{Name: "nginx", Error: "boom"}},
+		}
+		assert.EqualError(t, result.err(), "1 of 2 repositories failed: nginx (boom)")
+	})
+}
+
+func TestResultSummary(t *testing.T) {
+	result := Result{
+		Succeeded: []string{"snort", "nginx"},
+		Failed:    []FailedRepo{{Name: "apache2", Error: "boom"}},
+	}
+	expected := "Succeeded (2): snort, nginx\nSkipped (0): \nFailed (1):\n  apache2: boom\n"
+
+	assert.Equal(t, expected, result.Summary())
+}
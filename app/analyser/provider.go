@@ -0,0 +1,215 @@
+package analyser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// A GitHub or Gitea Pull Request, or a GitLab Merge Request
+type ChangeRequest struct {
+	// Number of the Pull Request, or iid of the Merge Request
+	Number int
+	URL    string
+	// Head branch the changes are on
+	Branch string
+}
+
+// Everything the analyser needs from wherever a repository is hosted
+type Provider interface {
+	DefaultBranch(ctx context.Context, repo Repository) (string, error)
+	LatestCommit(ctx context.Context, repo Repository) (string, error)
+	// HTTPS URL with credentials to clone from and push to
+	CloneURL(ctx context.Context, repo Repository) (string, error)
+	// Returns the open change request from branch, nil if there isn't one
+	FindChangeRequest(ctx context.Context, repo Repository, branch string) (*ChangeRequest, error)
+	// Returns the change request along with ErrReviewersNotRequested when
+	// only requesting the reviewers failed
+	OpenChangeRequest(ctx context.Context, repo Repository, branch string, details ChangeRequestDetails) (*ChangeRequest, error)
+	// Replaces the title and body, adds any missing labels and assignees and
+	// brings the requested reviewers in line with details, returning
+	// ErrReviewersNotRequested when only the reviewers couldn't be
+	UpdateChangeRequest(ctx context.Context, repo Repository, change *ChangeRequest, details ChangeRequestDetails) error
+	// Returns the open change requests from branches of the repository itself
+	// starting with prefix, leaving out those from forks
+	ListChangeRequests(ctx context.Context, repo Repository, prefix string) ([]*ChangeRequest, error)
+	// Reports whether the latest change request from branch was closed without merging
+	ChangeRequestDeclined(ctx context.Context, repo Repository, branch string) (bool, error)
+	// Leaves comment on change, closes it and deletes its branch
+	CloseChangeRequest(ctx context.Context, repo Repository, change *ChangeRequest, comment string) error
+}
+
+// Everything on a change request besides its commits
+type ChangeRequestDetails struct {
+	Title     string
+	Body      string
+	Labels    []string
+	Assignees []string
+	// Users by login and teams as org/team, as they appear in CODEOWNERS
+	Reviewers []string
+}
+
+// Hidden at the end of a PR body, names the reviewers Stylelia requested so
+// later updates only withdraw those and leave anyone people added alone
+var reviewersMarkerPattern = regexp.MustCompile(`<!-- stylelia:reviewers ([^>]*) -->`)
+
+// The body with the reviewers hidden at its end, read back with requestedReviewers
+func (d ChangeRequestDetails) body() string {
+	if len(d.Reviewers) == 0 {
+		return d.Body
+	}
+
+	return fmt.Sprintf("%s\n\n<!-- stylelia:reviewers %s -->", d.Body, strings.Join(d.Reviewers, " "))
+}
+
+// Returns the reviewers Stylelia requested when it last wrote body
+func requestedReviewers(body string) []string {
+	match := reviewersMarkerPattern.FindStringSubmatch(body)
+	if match == nil {
+		return nil
+	}
+
+	return strings.Fields(match[1])
+}
+
+// Splits reviewers into users and the slugs of teams in org, teams of other organisations can't review
+func splitReviewers(org string, reviewers []string) ([]string, []string) {
+	var users, teams []string
+	for _, reviewer := range reviewers {
+		team := strings.SplitN(reviewer, "/", 2)
+		switch {
+		case len(team) == 1:
+			users = append(users, reviewer)
+		case strings.EqualFold(team[0], org):
+			teams = append(teams, team[1])
+		}
+	}
+
+	return users, teams
+}
+
+// Appends the values in b missing from a, ignoring case like logins and label names do
+func mergeUnique(a, b []string) []string {
+	merged := append([]string{}, a...)
+	for _, value := range b {
+		if !containsFold(merged, value) {
+			merged = append(merged, value)
+		}
+	}
+
+	return merged
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var errScanUnsupported = errors.New("scan: only GitHub organisations can be scanned")
+
+// Returns the provider hosting a repository, name may be empty to look up an organisation
+func (h *Handler) provider(ctx context.Context, org, name string) (Provider, error) {
+	if h.providerFor != nil {
+		return h.providerFor(ctx, org, name)
+	}
+
+	gitlab, ok := h.Config.gitlabServer(org, name)
+	if ok {
+		return newGitlabProvider(h.Client, gitlab), nil
+	}
+
+	gitea, ok := h.Config.giteaServer(org, name)
+	if ok {
+		return newGiteaProvider(h.Client, gitea), nil
+	}
+
+	client, err := h.githubClient(ctx, org, name)
+	if err != nil {
+		return nil, err
+	}
+
+	server := h.Config.githubServer(org, name)
+	return &githubProvider{
+		client:  client,
+		gitHost: server.gitHost(),
+		token: func() (string, error) {
+			return h.githubToken(ctx, org, name)
+		},
+	}, nil
+}
+
+// A JSON REST API authenticated with a token header, for providers without a Go client
+type restAPI struct {
+	// Prefixes errors, e.g. gitlab
+	name       string
+	client     *http.Client
+	baseURL    string
+	authHeader string
+	authValue  string
+}
+
+// Sends a request, encoding body and decoding the response into out when given
+func (a restAPI) do(ctx context.Context, method, endpoint string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(content)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, a.baseURL+endpoint, reader)
+	if err != nil {
+		return err
+	}
+	request.Header.Set(a.authHeader, a.authValue)
+	request.Header.Set("Accept", "application/json")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := a.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return a.error(method, endpoint, response)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+// Wraps the statuses we care about in our typed errors
+func (a restAPI) error(method, endpoint string, response *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+	err := fmt.Errorf("%s: %s %s: %s: %s", a.name, method, endpoint, response.Status, bytes.TrimSpace(message))
+
+	switch response.StatusCode {
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w: %v", ErrUnauthorised, err)
+	case http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %v", ErrRateLimited, err)
+	}
+
+	return err
+}
@@ -0,0 +1,54 @@
+package analyser
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/youshy/logger"
+)
+
+func TestHandlerProvider(t *testing.T) {
+	handler := Handler{
+		Client: &http.Client{},
+		Log:    logger.NewLogger(logger.DEBUG, false),
+		Tokens: staticToken("privateToken"),
+		Config: Config{
+			GitlabServers: map[string]GitlabServer{
+				"chef/cookbooks": {Token: "glToken"},
+				"stylelia/snort": {URL: "https://git.example.com", Token: "glToken"},
+			},
+			GiteaServers: map[string]GiteaServer{
+				"airgap": {URL: "https://gitea.example.com", Token: "gtToken"},
+			},
+		},
+	}
+
+	testCases := []struct {
+		desc     string
+		org      string
+		name     string
+		expected interface{}
+	}{
+		{desc: "Repositories default to GitHub", org: "stylelia", name: "nginx", expected: &githubProvider{}},
+		{desc: "Repositories listed under gitlab_servers are on GitLab", org: "stylelia", name: "snort", expected: &gitlabProvider{}},
+		{desc: "Groups listed under gitlab_servers are on GitLab", org: "chef/cookbooks", name: "nginx", expected: &gitlabProvider{}},
+		{desc: "Organisations listed under gitea_servers are on Gitea", org: "airgap", name: "nginx", expected: &giteaProvider{}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			provider, err := handler.provider(context.Background(), tC.org, tC.name)
+			assert.NoError(t, err)
+			assert.IsType(t, tC.expected, provider)
+		})
+	}
+
+	t.Run("GitLab groups and Gitea organisations can't be scanned", func(t *testing.T) {
+		_, err := handler.handle(context.Background(), Event{Organisation: "chef/cookbooks", Scan: true})
+		assert.ErrorIs(t, err, errScanUnsupported)
+
+		_, err = handler.handle(context.Background(), Event{Organisation: "airgap", Scan: true})
+		assert.ErrorIs(t, err, errScanUnsupported)
+	})
+}
@@ -0,0 +1,85 @@
+package analyser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// Most messages SQS takes in one SendMessageBatch
+const sqsBatchSize int = 10
+
+// Where a release sends an event per repository, for HandleSQSEvent to run
+// each in an invocation of its own
+type eventQueue interface {
+	Send(ctx context.Context, events []Event) error
+}
+
+type sqsQueue struct {
+	client *sqs.Client
+	url    string
+}
+
+// Connects to the queue at url with the credentials and region of the environment
+func newSQSQueue(ctx context.Context, url string) (*sqsQueue, error) {
+	config, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqsQueue{client: sqs.NewFromConfig(config), url: url}, nil
+}
+
+// Sends one message per event, in batches of sqsBatchSize
+func (q *sqsQueue) Send(ctx context.Context, events []Event) error {
+	for start := 0; start < len(events); start += sqsBatchSize {
+		end := start + sqsBatchSize
+		if end > len(events) {
+			end = len(events)
+		}
+
+		entries := make([]types.SendMessageBatchRequestEntry, 0, end-start)
+		for i, event := range events[start:end] {
+			body, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, types.SendMessageBatchRequestEntry{
+				Id:          aws.String(strconv.Itoa(i)),
+				MessageBody: aws.String(string(body)),
+			})
+		}
+
+		output, err := q.client.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{QueueUrl: aws.String(q.url), Entries: entries})
+		if err != nil {
+			return err
+		}
+		// A batch succeeds even when some of its messages didn't make it
+		if len(output.Failed) > 0 {
+			return fmt.Errorf("sqs: %d of %d messages not sent: %s", len(output.Failed), len(entries), aws.ToString(output.Failed[0].Message))
+		}
+	}
+
+	return nil
+}
+
+// The event for each repository of a release
+func repositoryEvents(release Event, repos []Repository) []Event {
+	events := make([]Event, 0, len(repos))
+	for _, repo := range repos {
+		events = append(events, Event{
+			Organisation: repo.Org,
+			Name:         repo.Name,
+			Tools:        release.Tools,
+			Force:        release.Force,
+		})
+	}
+
+	return events
+}
@@ -0,0 +1,74 @@
+package analyser
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/assert"
+)
+
+// An SQS endpoint recording the messages of every batch, failing those with failBody
+func newFakeSQS(t *testing.T, failBody string) (*sqsQueue, *[][]string, func()) {
+	var batches [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "SendMessageBatch", r.Form.Get("Action"))
+
+		var bodies []string
+		var failed string
+		for i := 1; r.Form.Get(fmt.Sprintf("SendMessageBatchRequestEntry.%d.Id", i)) != ""; i++ {
+			body := r.Form.Get(fmt.Sprintf("SendMessageBatchRequestEntry.%d.MessageBody", i))
+			bodies = append(bodies, body)
+			if body == failBody {
+				failed += fmt.Sprintf("<BatchResultErrorEntry><Id>%s</Id><Code>InvalidMessageContents</Code><Message>Too big</Message><SenderFault>true</SenderFault></BatchResultErrorEntry>", r.Form.Get(fmt.Sprintf("SendMessageBatchRequestEntry.%d.Id", i)))
+			}
+		}
+		batches = append(batches, bodies)
+
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintf(w, "<SendMessageBatchResponse><SendMessageBatchResult>%s</SendMessageBatchResult><ResponseMetadata><RequestId>1</RequestId></ResponseMetadata></SendMessageBatchResponse>", failed)
+	}))
+
+	client := sqs.New(sqs.Options{
+		Region:           "eu-west-1",
+		Credentials:      aws.AnonymousCredentials{},
+		EndpointResolver: sqs.EndpointResolverFromURL(server.URL),
+	})
+	return &sqsQueue{client: client, url: server.URL + "/123456789012/stylelia"}, &batches, server.Close
+}
+
+func TestSQSQueueSend(t *testing.T) {
+	ctx := context.Background()
+	var events []Event
+	for i := 0; i < 25; i++ {
+		events = append(events, Event{Organisation: "stylelia", Name: fmt.Sprintf("cookbook%02d", i)})
+	}
+
+	t.Run("Sends an event per message, ten at a time", func(t *testing.T) {
+		queue, batches, close := newFakeSQS(t, "")
+		defer close()
+
+		err := queue.Send(ctx, events)
+		assert.NoError(t, err)
+		assert.Len(t, *batches, 3)
+		assert.Len(t, (*batches)[0], 10)
+		assert.Len(t, (*batches)[2], 5)
+		assert.Equal(t, `{"organisation":"stylelia","name":"cookbook24","tools":null,"force":false,"dry_run":false,"scan":false,"marker":"","release":false,"concurrency":0}`, (*batches)[2][4])
+	})
+
+	t.Run("Errors when a message in a batch isn't sent", func(t *testing.T) {
+		failBody := `{"organisation":"stylelia","name":"cookbook03","tools":null,"force":false,"dry_run":false,"scan":false,"marker":"","release":false,"concurrency":0}`
+		queue, batches, close := newFakeSQS(t, failBody)
+		defer close()
+
+		err := queue.Send(ctx, events)
+		assert.EqualError(t, err, "sqs: 1 of 10 messages not sent: Too big")
+		assert.Len(t, *batches, 1)
+		assert.Equal(t, failBody, (*batches)[0][3])
+	})
+}
@@ -0,0 +1,184 @@
+package analyser
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Requests left in the budget below which we wait for it to reset
+	rateLimitThreshold int = 100
+	// Longest we'll wait for a reset, any longer and the request fails with
+	// ErrRateLimited so the repository is picked up again on a later run
+	rateLimitMaxWait time.Duration = 5 * time.Minute
+
+	headerRateRemaining string = "X-RateLimit-Remaining"
+	headerRateReset     string = "X-RateLimit-Reset"
+	headerETag          string = "ETag"
+	headerIfNoneMatch   string = "If-None-Match"
+)
+
+// Tracks the rate limit budget of the token of each organisation,
+// shared by every GitHub client the handler makes
+type rateLimiter struct {
+	mu      sync.Mutex
+	budgets map[string]rateBudget
+
+	now   func() time.Time
+	sleep func(context.Context, time.Duration) error
+}
+
+type rateBudget struct {
+	remaining int
+	reset     time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		budgets: make(map[string]rateBudget),
+		now:     time.Now,
+		sleep:   sleepContext,
+	}
+}
+
+// Blocks until org has enough of its budget left to make a request
+func (l *rateLimiter) wait(ctx context.Context, org string, log *zap.SugaredLogger) error {
+	l.mu.Lock()
+	budget, ok := l.budgets[org]
+	l.mu.Unlock()
+
+	if !ok || budget.remaining >= rateLimitThreshold {
+		return nil
+	}
+
+	wait := budget.reset.Sub(l.now())
+	if wait <= 0 {
+		return nil
+	}
+	if wait > rateLimitMaxWait {
+		return fmt.Errorf("%w: %d requests left for %s until %s", ErrRateLimited, budget.remaining, org, budget.reset.Format(time.RFC3339))
+	}
+
+	log.Infof("Only %d GitHub requests left for %s, waiting %s for the reset", budget.remaining, org, wait)
+	return l.sleep(ctx, wait)
+}
+
+// Records the budget GitHub reported on a response
+func (l *rateLimiter) update(org string, response *http.Response) {
+	remaining, err := strconv.Atoi(response.Header.Get(headerRateRemaining))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(response.Header.Get(headerRateReset), 10, 64)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.budgets[org] = rateBudget{remaining: remaining, reset: time.Unix(reset, 0)}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// The repository and commit endpoints every run polls to see whether
+// anything changed. Only these are cached: lists would lose the Link header
+// they're paged by on a 304 and the rest aren't asked for again.
+var conditionalEndpoint = regexp.MustCompile(`/repos/[^/]+/[^/]+(/commits/.+)?$`)
+
+// Sits in front of every GitHub API request: waits when the rate limit
+// budget runs low and makes GETs of conditionalEndpoint conditional on the
+// ETag of the last response, answering 304s from the store. GitHub doesn't
+// count a 304 against the rate limit.
+type rateLimitTransport struct {
+	base http.RoundTripper
+	// Every token has its own budget, tracked by server and organisation
+	budget  string
+	limiter *rateLimiter
+	store   KeyValueStore
+	log     *zap.SugaredLogger
+}
+
+func (t *rateLimitTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx := r.Context()
+
+	err := t.limiter.wait(ctx, t.budget, t.log)
+	if err != nil {
+		return nil, err
+	}
+
+	// Some calls, like GetCommitSHA1, already set their own
+	conditional := r.Method == http.MethodGet && r.Header.Get(headerIfNoneMatch) == "" && conditionalEndpoint.MatchString(r.URL.Path)
+
+	var key, etag, body string
+	if conditional {
+		// The same URL returns different bodies for different media types
+		key = r.Header.Get("Accept") + " " + r.URL.String()
+		etag, body, err = t.store.GetETag(ctx, key)
+		if err != nil {
+			t.log.Debugf("No ETag cached for %s: %v", key, err)
+			etag = ""
+		}
+		if etag != "" {
+			r = r.Clone(ctx)
+			r.Header.Set(headerIfNoneMatch, etag)
+		}
+	}
+
+	response, err := t.base.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	t.limiter.update(t.budget, response)
+
+	if !conditional {
+		return response, nil
+	}
+
+	if response.StatusCode == http.StatusNotModified && etag != "" {
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+
+		response.StatusCode = http.StatusOK
+		response.Status = "200 OK"
+		response.Body = io.NopCloser(bytes.NewBufferString(body))
+		response.ContentLength = int64(len(body))
+		return response, nil
+	}
+
+	newETag := response.Header.Get(headerETag)
+	if response.StatusCode == http.StatusOK && newETag != "" {
+		content, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		response.Body = io.NopCloser(bytes.NewReader(content))
+
+		err = t.store.UpdateETag(ctx, key, newETag, string(content))
+		if err != nil {
+			t.log.Warnf("Unable to update ETag in cache: %v", err)
+		}
+	}
+
+	return response, nil
+}
@@ -0,0 +1,191 @@
+package analyser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v39/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/styleila/analyser/pkg/inmemorycache"
+	"github.com/youshy/logger"
+)
+
+func newTestRateLimitClient(server *httptest.Server, limiter *rateLimiter, store KeyValueStore) *github.Client {
+	handler := Handler{
+		Log:        logger.NewLogger(logger.DEBUG, false),
+		Store:      store,
+		Tokens:     staticToken("privateToken"),
+		rateLimits: limiter,
+	}
+
+	client, err := handler.githubClient(context.Background(), "stylelia", "")
+	if err != nil {
+		panic(err)
+	}
+	client.BaseURL.Scheme = "http"
+	client.BaseURL.Host = server.Listener.Addr().String()
+
+	return client
+}
+
+func TestRateLimitTransportETags(t *testing.T) {
+	t.Run("Answers a 304 from the cache without spending the budget", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if r.Header.Get("If-None-Match") == `"abc"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"abc"`)
+			fmt.Fprint(w, `{"default_branch": "main"}`)
+		}))
+		defer server.Close()
+
+		store := inmemorycache.NewInMemoryCache()
+		client := newTestRateLimitClient(server, newRateLimiter(), store)
+
+		for i := 0; i < 2; i++ {
+			branch, err := getDefaultBranch(context.Background(), client, "stylelia", "cookbook")
+			assert.NoError(t, err)
+			assert.Equal(t, "main", branch)
+		}
+		assert.Equal(t, 2, requests)
+	})
+
+	t.Run("Caches the latest commit of a branch", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			assert.Equal(t, "/repos/stylelia/cookbook/commits/release/1.x", r.URL.Path)
+			if r.Header.Get("If-None-Match") == `"abc"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"abc"`)
+			fmt.Fprint(w, `b64d5bae3cee6da8c305c0f46f678914cb22e483`)
+		}))
+		defer server.Close()
+
+		client := newTestRateLimitClient(server, newRateLimiter(), inmemorycache.NewInMemoryCache())
+
+		for i := 0; i < 2; i++ {
+			sha, _, err := client.Repositories.GetCommitSHA1(context.Background(), "stylelia", "cookbook", "release/1.x", "")
+			assert.NoError(t, err)
+			assert.Equal(t, "b64d5bae3cee6da8c305c0f46f678914cb22e483", sha)
+		}
+		assert.Equal(t, 2, requests)
+	})
+
+	t.Run("Leaves paged lists alone so they keep their Link header", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Empty(t, r.Header.Get("If-None-Match"))
+			w.Header().Set("ETag", `"abc"`)
+			w.Header().Set("Link", `<http://`+r.Host+`/repos/stylelia/cookbook/pulls?page=2>; rel="next"`)
+			fmt.Fprint(w, `[{"number": 4}]`)
+		}))
+		defer server.Close()
+
+		client := newTestRateLimitClient(server, newRateLimiter(), inmemorycache.NewInMemoryCache())
+
+		for i := 0; i < 2; i++ {
+			_, response, err := client.PullRequests.List(context.Background(), "stylelia", "cookbook", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, 2, response.NextPage)
+		}
+	})
+
+	t.Run("Sends requests without an ETag when the cache is empty", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Empty(t, r.Header.Get("If-None-Match"))
+			fmt.Fprint(w, `{"default_branch": "main"}`)
+		}))
+		defer server.Close()
+
+		client := newTestRateLimitClient(server, newRateLimiter(), inmemorycache.NewInMemoryCache())
+
+		branch, err := getDefaultBranch(context.Background(), client, "stylelia", "cookbook")
+		assert.NoError(t, err)
+		assert.Equal(t, "main", branch)
+	})
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	log := logger.NewLogger(logger.DEBUG, false)
+
+	newLimiter := func(remaining int, reset time.Time) (*rateLimiter, *time.Duration) {
+		slept := new(time.Duration)
+		limiter := newRateLimiter()
+		limiter.now = func() time.Time { return now }
+		limiter.sleep = func(ctx context.Context, d time.Duration) error {
+			*slept = d
+			return nil
+		}
+		limiter.budgets["github.com/stylelia"] = rateBudget{remaining: remaining, reset: reset}
+		return limiter, slept
+	}
+
+	t.Run("Doesn't wait with plenty of budget left", func(t *testing.T) {
+		limiter, slept := newLimiter(4000, now.Add(time.Minute))
+
+		err := limiter.wait(context.Background(), "github.com/stylelia", log)
+		assert.NoError(t, err)
+		assert.Zero(t, *slept)
+	})
+
+	t.Run("Waits for the reset when the budget runs low", func(t *testing.T) {
+		limiter, slept := newLimiter(10, now.Add(time.Minute))
+
+		err := limiter.wait(context.Background(), "github.com/stylelia", log)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Minute, *slept)
+	})
+
+	t.Run("Gives up when the reset is too far away", func(t *testing.T) {
+		limiter, slept := newLimiter(10, now.Add(time.Hour))
+
+		err := limiter.wait(context.Background(), "github.com/stylelia", log)
+		assert.True(t, errors.Is(err, ErrRateLimited))
+		assert.Zero(t, *slept)
+	})
+
+	t.Run("Doesn't wait once the reset has passed", func(t *testing.T) {
+		limiter, slept := newLimiter(0, now.Add(-time.Minute))
+
+		err := limiter.wait(context.Background(), "github.com/stylelia", log)
+		assert.NoError(t, err)
+		assert.Zero(t, *slept)
+	})
+
+	t.Run("Records the budget from the response headers", func(t *testing.T) {
+		limiter := newRateLimiter()
+		response := &http.Response{Header: http.Header{}}
+		response.Header.Set("X-RateLimit-Remaining", "42")
+		response.Header.Set("X-RateLimit-Reset", "1600000060")
+
+		limiter.update("github.com/stylelia", response)
+		assert.Equal(t, rateBudget{remaining: 42, reset: now.Add(time.Minute)}, limiter.budgets["github.com/stylelia"])
+	})
+
+	t.Run("Fails requests with ErrRateLimited when the budget is spent", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", fmt.Sprint(time.Now().Add(time.Hour).Unix()))
+			fmt.Fprint(w, `{"default_branch": "main"}`)
+		}))
+		defer server.Close()
+
+		client := newTestRateLimitClient(server, newRateLimiter(), inmemorycache.NewInMemoryCache())
+
+		_, err := getDefaultBranch(context.Background(), client, "stylelia", "cookbook")
+		assert.NoError(t, err)
+		_, err = getDefaultBranch(context.Background(), client, "stylelia", "another")
+		assert.True(t, errors.Is(err, ErrRateLimited))
+	})
+}
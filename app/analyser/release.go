@@ -0,0 +1,77 @@
+package analyser
+
+import (
+	"context"
+	"strings"
+)
+
+// Runs every repository in the cache when a new Cookstyle has been released.
+// Inline they all have to finish within one Lambda invocation, with a release
+// queue each is sent to SQS and runs in an invocation of its own.
+func (h *Handler) fanOut(ctx context.Context, event Event) (Result, error) {
+	return h.fanOutRelease(ctx, event, cookstyleApi)
+}
+
+func (h *Handler) fanOutRelease(ctx context.Context, event Event, cookstyleEndpoint string) (Result, error) {
+	cookstyleVersion, err := getLatestCookstyle(cookstyleEndpoint, h.Client)
+	if err != nil {
+		h.Log.Errorf("Unable to get latest cookstyle version: %v", err)
+		return Result{}, err
+	}
+
+	lastSeen, err := h.Store.GetGlobalToolVersion(ctx, Cookstyle)
+	if err != nil {
+		h.Log.Errorf("Unable to get last seen cookstyle version: %v", err)
+		return Result{}, err
+	}
+
+	if cookstyleVersion == lastSeen && !event.Force {
+		h.Log.Infof("No new Cookstyle release, still on %s", cookstyleVersion)
+		return Result{}, nil
+	}
+	h.Log.Infof("Cookstyle %s released, last seen %q", cookstyleVersion, lastSeen)
+
+	tracked, err := h.Store.ListRepositories(ctx)
+	if err != nil {
+		h.Log.Errorf("Unable to list tracked repositories: %v", err)
+		return Result{}, err
+	}
+
+	repos := make([]Repository, 0, len(tracked))
+	for _, fullName := range tracked {
+		parts := strings.SplitN(fullName, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			h.Log.Warnf("Ignoring malformed repository %q in cache", fullName)
+			continue
+		}
+		repos = append(repos, NewRepo(parts[0], parts[1], ""))
+	}
+	var result Result
+	// Dry runs stay inline, their results would be lost on the queue
+	if h.queue != nil && !event.DryRun {
+		// Every repository runs in an invocation of its own, SQS retries those that fail
+		err = h.queue.Send(ctx, repositoryEvents(event, repos))
+		if err != nil {
+			h.Log.Errorf("Unable to queue tracked repositories: %v", err)
+			return result, err
+		}
+		h.Log.Infof("Queued %d tracked repositories", len(repos))
+	} else {
+		h.Log.Infof("Running %d tracked repositories", len(repos))
+		result = h.runPool(ctx, event, repos)
+
+		// Leave the last seen version alone so failed repositories are retried on the next poll
+		err = result.err()
+		if err != nil || event.DryRun {
+			return result, err
+		}
+	}
+
+	err = h.Store.UpdateGlobalToolVersion(ctx, Cookstyle, cookstyleVersion)
+	if err != nil {
+		h.Log.Errorf("Unable to update last seen cookstyle version: %v", err)
+		return result, err
+	}
+
+	return result, nil
+}
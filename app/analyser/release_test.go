@@ -0,0 +1,139 @@
+package analyser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/styleila/analyser/pkg/inmemorycache"
+	"github.com/youshy/logger"
+)
+
+func TestFanOutRelease(t *testing.T) {
+	var mu sync.Mutex
+	var analysed []string
+	analyseRepo := func(h *Handler, ctx context.Context, event Event) (Outcome, *DryRun, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		analysed = append(analysed, fmt.Sprintf("%s/%s", event.Organisation, event.Name))
+		return Succeeded, nil, nil
+	}
+
+	rubygems := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"version": "7.26.0"}`)
+	}))
+	defer rubygems.Close()
+
+	handler := NewHandler(&http.Client{}, logger.NewLogger(logger.DEBUG, false), Config{})
+	handler.WorkingDir = t.TempDir()
+	handler.analyseRepo = analyseRepo
+	ctx := context.Background()
+
+	newStore := func(lastSeen string) *inmemorycache.InMemoryCache {
+		store := inmemorycache.NewInMemoryCache()
+		_ = store.UpdateGlobalToolVersion(ctx, Cookstyle, lastSeen)
+		_ = store.UpdateCommitSha(ctx, "stylelia", "snort", "abc")
+		_ = store.UpdateCommitSha(ctx, "sous-chefs", "nginx", "def")
+		return store
+	}
+
+	t.Run("A new release runs every tracked repository", func(t *testing.T) {
+		analysed = nil
+		store := newStore("7.25.6")
+		handler.Store = store
+
+		result, err := handler.fanOutRelease(ctx, Event{Concurrency: 2}, rubygems.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"sous-chefs/nginx", "stylelia/snort"}, result.Succeeded)
+
+		sort.Strings(analysed)
+		assert.Equal(t, []string{"sous-chefs/nginx", "stylelia/snort"}, analysed)
+
+		lastSeen, err := store.GetGlobalToolVersion(ctx, Cookstyle)
+		assert.NoError(t, err)
+		assert.Equal(t, "7.26.0", lastSeen)
+	})
+
+	t.Run("The same release does nothing", func(t *testing.T) {
+		analysed = nil
+		store := newStore("7.26.0")
+		handler.Store = store
+
+		result, err := handler.fanOutRelease(ctx, Event{Concurrency: 2}, rubygems.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, Result{}, result)
+		assert.Empty(t, analysed)
+	})
+
+	t.Run("A dry run leaves the last seen version alone", func(t *testing.T) {
+		analysed = nil
+		store := newStore("7.25.6")
+		handler.Store = store
+
+		_, err := handler.fanOutRelease(ctx, Event{Concurrency: 2, DryRun: true}, rubygems.URL)
+		assert.NoError(t, err)
+		assert.Len(t, analysed, 2)
+
+		lastSeen, err := store.GetGlobalToolVersion(ctx, Cookstyle)
+		assert.NoError(t, err)
+		assert.Equal(t, "7.25.6", lastSeen)
+	})
+	t.Run("A release queue gets an event per repository instead", func(t *testing.T) {
+		analysed = nil
+		store := newStore("7.25.6")
+		queue := &fakeQueue{}
+		handler := handler
+		handler.Store = store
+		handler.queue = queue
+
+		result, err := handler.fanOutRelease(ctx, Event{Concurrency: 2, Tools: []string{Cookstyle}}, rubygems.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, Result{}, result)
+		assert.Empty(t, analysed)
+
+		sort.Slice(queue.sent, func(i, j int) bool { return queue.sent[i].Organisation < queue.sent[j].Organisation })
+		assert.Equal(t, []Event{
+			{Organisation: "sous-chefs", Name: "nginx", Tools: []string{Cookstyle}},
+			{Organisation: "stylelia", Name: "snort", Tools: []string{Cookstyle}},
+		}, queue.sent)
+
+		lastSeen, err := store.GetGlobalToolVersion(ctx, Cookstyle)
+		assert.NoError(t, err)
+		assert.Equal(t, "7.26.0", lastSeen)
+	})
+
+	t.Run("A release that can't be queued is tried again on the next poll", func(t *testing.T) {
+		store := newStore("7.25.6")
+		handler := handler
+		handler.Store = store
+		handler.queue = &fakeQueue{err: errors.New("AccessDenied")}
+
+		_, err := handler.fanOutRelease(ctx, Event{Concurrency: 2}, rubygems.URL)
+		assert.EqualError(t, err, "AccessDenied")
+
+		lastSeen, err := store.GetGlobalToolVersion(ctx, Cookstyle)
+		assert.NoError(t, err)
+		assert.Equal(t, "7.25.6", lastSeen)
+	})
+}
+
+// Records the events sent, or fails with err
+type fakeQueue struct {
+	sent []Event
+	err  error
+}
+
+func (f *fakeQueue) Send(ctx context.Context, events []Event) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, events...)
+	return nil
+}
@@ -1,9 +1,22 @@
 package analyser
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+
+	"github.com/google/go-github/v39/github"
+)
+
+// Errors for provider responses we handle differently, check with errors.Is
+var (
+	ErrNotFound     = errors.New("scm: not found")
+	ErrRateLimited  = errors.New("scm: rate limited")
+	ErrUnauthorised = errors.New("scm: unauthorised")
+	// The change request is there but its reviewers couldn't be requested,
+	// e.g. a CODEOWNERS user left the organisation
+	ErrReviewersNotRequested = errors.New("scm: reviewers not requested")
 )
 
 // Repository structs
@@ -12,15 +25,8 @@ type Repository struct {
 	Name          string
 	DefaultBranch string
 	LatestCommit  string
-	GithubApi     string
-}
-
-type GetRepository struct {
-	DefaultBranch string `json:"default_branch"`
-}
-
-type GetLastCommit struct {
-	Sha string `json:"sha"`
+	// The bot's fork changes are pushed to when it can't push here, nil otherwise
+	Fork *Repository
 }
 
 func NewRepo(org, name, defaultBranch string) Repository {
@@ -31,52 +37,55 @@ func NewRepo(org, name, defaultBranch string) Repository {
 	}
 }
 
-func getDefaultBranch(endpoint string, client *http.Client) (string, error) {
-	request, err := http.NewRequest(http.MethodGet, endpoint, nil)
-	if err != nil {
-		return "", err
-	}
+// Returns the repository as org/name
+func (r Repository) FullName() string {
+	return fmt.Sprintf("%s/%s", r.Org, r.Name)
+}
 
-	response, err := client.Do(request)
+func getDefaultBranch(ctx context.Context, client *github.Client, org, name string) (string, error) {
+	repo, _, err := client.Repositories.Get(ctx, org, name)
 	if err != nil {
-		return "", err
+		return "", githubError(err)
 	}
-	defer response.Body.Close()
 
-	var getRepoStruct GetRepository
-	err = json.NewDecoder(response.Body).Decode(&getRepoStruct)
-	if err != nil {
-		return "", err
+	if repo.GetDefaultBranch() == "" {
+		return "", fmt.Errorf("github: %s/%s has no default branch", org, name)
 	}
 
-	return getRepoStruct.DefaultBranch, nil
+	return repo.GetDefaultBranch(), nil
 }
 
-func (r *Repository) getLastCommit(endpoint string, client *http.Client) error {
-	request, err := http.NewRequest(http.MethodGet, endpoint, nil)
-	if err != nil {
-		return err
-	}
-
-	response, err := client.Do(request)
-	if err != nil {
-		return err
-	}
-	defer response.Body.Close()
-
-	var getLastCommitStruct GetLastCommit
-	err = json.NewDecoder(response.Body).Decode(&getLastCommitStruct)
+func (r *Repository) getLastCommit(ctx context.Context, client *github.Client) error {
+	sha, _, err := client.Repositories.GetCommitSHA1(ctx, r.Org, r.Name, r.DefaultBranch, "")
 	if err != nil {
-		return err
+		return githubError(err)
 	}
 
-	r.LatestCommit = getLastCommitStruct.Sha
+	r.LatestCommit = sha
 
 	return nil
 }
 
-func (r *Repository) buildCommitEndpoint(githubApi string) string {
-	return fmt.Sprintf("%s/repos/%s/%s/commits/%s", githubApi, r.Org, r.Name, r.DefaultBranch)
+// Wraps the errors from go-github we care about in our typed errors,
+// anything else is returned as it is
+func githubError(err error) error {
+	var rateLimitErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &rateLimitErr) || errors.As(err, &abuseErr) {
+		return fmt.Errorf("%w: %v", ErrRateLimited, err)
+	}
+
+	var responseErr *github.ErrorResponse
+	if errors.As(err, &responseErr) && responseErr.Response != nil {
+		switch responseErr.Response.StatusCode {
+		case http.StatusNotFound:
+			return fmt.Errorf("%w: %v", ErrNotFound, err)
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return fmt.Errorf("%w: %v", ErrUnauthorised, err)
+		}
+	}
+
+	return err
 }
 
 func (r *Repository) Clone(exec CommandRunner) error {
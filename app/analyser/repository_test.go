@@ -1,12 +1,15 @@
 package analyser
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/google/go-github/v39/github"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -84,81 +87,94 @@ func TestRunCookstyle(t *testing.T) {
 func TestGetDefaultBranch(t *testing.T) {
 	defaultBranch := "ObiWanKenobiHadTheHigherGround"
 
-	gitBranchHandler := func(w http.ResponseWriter, r *http.Request) {
-		output := GetRepository{
-			DefaultBranch: defaultBranch,
-		}
-		response, err := json.Marshal(output)
-		if err != nil {
-			// we should never panic here.
-			// we should never reach here.
-			// we shouldn't use panic.
-			panic(err)
-		}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/someOrg/someName", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer privateToken", r.Header.Get("Authorization"))
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		_, err = w.Write(response)
-		if err != nil {
-			// we should never panic here.
-			// we should never reach here.
-			// we shouldn't use panic.
-			panic(err)
-		}
-	}
+		fmt.Fprintf(w, `{"default_branch": %q, "private": true}`, defaultBranch)
+	})
+	mux.HandleFunc("/repos/someOrg/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+	})
 
-	server := httptest.NewServer(http.HandlerFunc(gitBranchHandler))
+	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	client := &http.Client{}
+	client := createClientWithAuth(context.Background(), staticToken("privateToken").TokenSource(context.Background(), "someOrg"))
+	client.BaseURL = newTestGithubClient(server).BaseURL
+
+	t.Run("Returns the default branch of a private repository", func(t *testing.T) {
+		branch, err := getDefaultBranch(context.Background(), client, "someOrg", "someName")
+		assert.NoError(t, err)
+		assert.Equal(t, defaultBranch, branch)
+	})
 
-	branch, err := getDefaultBranch(server.URL, client)
-	assert.NoError(t, err)
-	assert.Equal(t, defaultBranch, branch)
+	t.Run("Returns ErrNotFound for a missing repository", func(t *testing.T) {
+		branch, err := getDefaultBranch(context.Background(), client, "someOrg", "missing")
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Equal(t, "", branch)
+	})
 }
 
 func TestGetLastCommit(t *testing.T) {
 	latestCommit := "YoungSkywalkerWasDoomedToFail"
 
-	gitBranchHandler := func(w http.ResponseWriter, r *http.Request) {
-		output := GetLastCommit{
-			Sha: latestCommit,
-		}
-		response, err := json.Marshal(output)
-		if err != nil {
-			// we should never panic here.
-			// we should never reach here.
-			// we shouldn't use panic.
-			panic(err)
-		}
-		w.Header().Set("Content-Type", "application/json")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/someOrg/someName/commits/defaultBranch", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
-		_, err = w.Write(response)
-		if err != nil {
-			// we should never panic here.
-			// we should never reach here.
-			// we shouldn't use panic.
-			panic(err)
-		}
-	}
+		fmt.Fprint(w, latestCommit)
+	})
+	mux.HandleFunc("/repos/someOrg/limited/commits/defaultBranch", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "60")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "1634567890")
+		http.Error(w, `{"message": "API rate limit exceeded"}`, http.StatusForbidden)
+	})
+	mux.HandleFunc("/repos/someOrg/private/commits/defaultBranch", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "Bad credentials"}`, http.StatusUnauthorized)
+	})
 
-	server := httptest.NewServer(http.HandlerFunc(gitBranchHandler))
+	server := httptest.NewServer(mux)
 	defer server.Close()
+	client := newTestGithubClient(server)
 
-	client := &http.Client{}
+	t.Run("Sets the latest commit on the repository", func(t *testing.T) {
+		repo := NewRepo("someOrg", "someName", "defaultBranch")
 
-	repo := NewRepo("someOrg", "someName", "defaultBranch")
+		err := repo.getLastCommit(context.Background(), client)
+		assert.NoError(t, err)
+		assert.Equal(t, latestCommit, repo.LatestCommit)
+	})
+
+	t.Run("Returns ErrRateLimited when out of requests", func(t *testing.T) {
+		repo := NewRepo("someOrg", "limited", "defaultBranch")
+
+		err := repo.getLastCommit(context.Background(), client)
+		assert.ErrorIs(t, err, ErrRateLimited)
+		assert.Equal(t, "", repo.LatestCommit)
+	})
+
+	t.Run("Returns ErrUnauthorised on bad credentials", func(t *testing.T) {
+		repo := NewRepo("someOrg", "private", "defaultBranch")
 
-	err := repo.getLastCommit(server.URL, client)
-	assert.NoError(t, err)
-	assert.Equal(t, latestCommit, repo.LatestCommit)
+		err := repo.getLastCommit(context.Background(), client)
+		assert.ErrorIs(t, err, ErrUnauthorised)
+	})
 }
 
-func TestBuildCommitEndpoint(t *testing.T) {
-	githubApi := "https://api.github.io"
-	expected := "https://api.github.io/repos/org/name/commits/branch"
+func TestGithubError(t *testing.T) {
+	t.Run("Other errors are returned as they are", func(t *testing.T) {
+		err := errors.New("connection refused")
+		assert.Equal(t, err, githubError(err))
+	})
 
-	repo := NewRepo("org", "name", "branch")
-	endpoint := repo.buildCommitEndpoint(githubApi)
+	t.Run("Other statuses are returned as they are", func(t *testing.T) {
+		err := &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusInternalServerError}}
+		assert.Equal(t, err, githubError(err))
+	})
+}
 
-	assert.Equal(t, expected, endpoint)
+func TestFullName(t *testing.T) {
+	repo := NewRepo("org", "name", "branch")
+	assert.Equal(t, "org/name", repo.FullName())
 }
@@ -0,0 +1,118 @@
+package analyser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config at the root of a repository, Cookstyle reads it like RuboCop does
+const rubocopConfig string = ".rubocop.yml"
+
+// Turns cops off in the repository's .rubocop.yml, creating it if there isn't
+// one. New cops are appended so the rest of the file is kept as it was, it's
+// only rewritten when a cop it already configures has to be turned off.
+func disableCops(workDir string, cops []string) error {
+	path := filepath.Join(workDir, rubocopConfig)
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	var doc yaml.Node
+	err = yaml.Unmarshal(content, &doc)
+	if err != nil {
+		return fmt.Errorf("%s: %v", rubocopConfig, err)
+	}
+
+	var root *yaml.Node
+	if len(doc.Content) > 0 {
+		root = doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s: not a mapping of cops to their settings", rubocopConfig)
+		}
+	}
+
+	var missing []string
+	rewrite := false
+	for _, cop := range cops {
+		settings := mappingValue(root, cop)
+		switch {
+		case settings == nil:
+			missing = append(missing, cop)
+		case settings.Kind != yaml.MappingNode:
+			// e.g. a cop listed with nothing under it
+			*settings = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			settings.Content = disabledSetting()
+			rewrite = true
+		default:
+			enabled := mappingValue(settings, "Enabled")
+			if enabled == nil {
+				settings.Content = append(settings.Content, disabledSetting()...)
+				rewrite = true
+			} else if enabled.Value != "false" {
+				*enabled = *disabledSetting()[1]
+				rewrite = true
+			}
+		}
+	}
+
+	if !rewrite && len(missing) == 0 {
+		return nil
+	}
+
+	if rewrite {
+		var buffer bytes.Buffer
+		encoder := yaml.NewEncoder(&buffer)
+		encoder.SetIndent(2)
+		err = encoder.Encode(&doc)
+		if err != nil {
+			return err
+		}
+		err = encoder.Close()
+		if err != nil {
+			return err
+		}
+		content = buffer.Bytes()
+	}
+
+	for _, cop := range missing {
+		if len(content) > 0 {
+			if !bytes.HasSuffix(content, []byte("\n")) {
+				content = append(content, '\n')
+			}
+			content = append(content, '\n')
+		}
+		content = append(content, fmt.Sprintf("%s:\n  Enabled: false\n", cop)...)
+	}
+
+	return os.WriteFile(path, content, 0644)
+}
+
+// Returns the value of key in a mapping node, nil if it isn't there
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping == nil {
+		return nil
+	}
+
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// The key and value nodes of Enabled: false
+func disabledSetting() []*yaml.Node {
+	return []*yaml.Node{
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: "Enabled"},
+		{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "false"},
+	}
+}
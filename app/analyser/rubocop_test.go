@@ -0,0 +1,72 @@
+package analyser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisableCops(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		existing string
+		cops     []string
+		expected string
+	}{
+		{
+			desc:     "Creates the config when there isn't one",
+			cops:     []string{"Chef/Style/CommentFormat"},
+			expected: "Chef/Style/CommentFormat:\n  Enabled: false\n",
+		},
+		{
+			desc:     "Appends new cops leaving the rest of the file alone",
+			existing: "# Our style\nAllCops:\n    TargetRubyVersion: 2.7",
+			cops:     []string{"Chef/Style/CommentFormat", "Chef/Modernize/FoodcriticComments"},
+			expected: "# Our style\nAllCops:\n    TargetRubyVersion: 2.7\n\nChef/Style/CommentFormat:\n  Enabled: false\n\nChef/Modernize/FoodcriticComments:\n  Enabled: false\n",
+		},
+		{
+			desc:     "Turns off a cop that's configured already",
+			existing: "Chef/Style/CommentFormat:\n  Exclude:\n    - test/**/*\n",
+			cops:     []string{"Chef/Style/CommentFormat"},
+			expected: "Chef/Style/CommentFormat:\n  Exclude:\n    - test/**/*\n  Enabled: false\n",
+		},
+		{
+			desc:     "Turns off a cop that's enabled",
+			existing: "Chef/Style/CommentFormat:\n  Enabled: true\n",
+			cops:     []string{"Chef/Style/CommentFormat"},
+			expected: "Chef/Style/CommentFormat:\n  Enabled: false\n",
+		},
+		{
+			desc:     "Leaves a file turning the cops off already untouched",
+			existing: "Chef/Style/CommentFormat:\n    Enabled: false # Noisy\n",
+			cops:     []string{"Chef/Style/CommentFormat"},
+			expected: "Chef/Style/CommentFormat:\n    Enabled: false # Noisy\n",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			workDir := t.TempDir()
+			path := filepath.Join(workDir, rubocopConfig)
+			if tC.existing != "" {
+				assert.NoError(t, os.WriteFile(path, []byte(tC.existing), 0644))
+			}
+
+			err := disableCops(workDir, tC.cops)
+			assert.NoError(t, err)
+
+			actual, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, tC.expected, string(actual))
+		})
+	}
+
+	t.Run("Errors for a config that isn't a mapping", func(t *testing.T) {
+		workDir := t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(workDir, rubocopConfig), []byte("- Chef/Style/CommentFormat\n"), 0644))
+
+		err := disableCops(workDir, []string{"Chef/Style/CommentFormat"})
+		assert.Error(t, err)
+	})
+}
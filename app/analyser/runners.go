@@ -1,7 +1,22 @@
 package analyser
 
+import (
+	"errors"
+	"os/exec"
+)
+
 // Interface for all exec.Command stuff
 type CommandRunner interface {
 	Run() error
 	Output() ([]byte, error)
 }
+
+// Returns the exit code of a command that ran and failed, -1 for any other error
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	return -1
+}
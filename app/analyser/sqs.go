@@ -0,0 +1,54 @@
+package analyser
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Lambda handler for an SQS queue of events, one per message
+// Only the messages that failed are reported back so SQS retries just those,
+// this needs ReportBatchItemFailures turned on for the event source mapping
+func HandleSQSEvent(ctx context.Context, sqsEvent events.SQSEvent) (events.SQSEventResponse, error) {
+	// A bad config fails every message, so fail the whole invocation instead
+	config, err := LoadConfigFromEnv()
+	if err != nil {
+		return events.SQSEventResponse{}, err
+	}
+	err = config.Validate()
+	if err != nil {
+		return events.SQSEventResponse{}, err
+	}
+
+	handler, err := newHandlerFromConfig(config)
+	if err != nil {
+		return events.SQSEventResponse{}, err
+	}
+
+	return handler.handleSQS(ctx, sqsEvent), nil
+}
+
+func (h *Handler) handleSQS(ctx context.Context, sqsEvent events.SQSEvent) events.SQSEventResponse {
+	response := events.SQSEventResponse{
+		BatchItemFailures: []events.SQSBatchItemFailure{},
+	}
+
+	for _, message := range sqsEvent.Records {
+		var event Event
+		err := json.Unmarshal([]byte(message.Body), &event)
+		if err != nil {
+			h.Log.Errorf("Unable to decode message %s: %v", message.MessageId, err)
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: message.MessageId})
+			continue
+		}
+
+		_, err = h.handle(ctx, event)
+		if err != nil {
+			h.Log.Errorf("Unable to process message %s: %v", message.MessageId, err)
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: message.MessageId})
+		}
+	}
+
+	return response
+}
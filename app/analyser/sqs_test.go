@@ -0,0 +1,62 @@
+package analyser
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/youshy/logger"
+)
+
+func TestHandleSQS(t *testing.T) {
+	analyseRepo := func(h *Handler, ctx context.Context, event Event) (Outcome, *DryRun, error) {
+		if event.Name == "broken" {
+			return Failed, nil, errors.New("clone failed")
+		}
+		return Succeeded, nil, nil
+	}
+
+	handler := NewHandler(nil, logger.NewLogger(logger.DEBUG, false), Config{})
+	handler.WorkingDir = t.TempDir()
+	handler.analyseRepo = analyseRepo
+
+	sqsEvent := events.SQSEvent{
+		Records: []events.SQSMessage{
+			{MessageId: "1", Body: `{"organisation": "stylelia", "name": "snort"}`},
+			{MessageId: "2", Body: `{"organisation": "stylelia", "name": "broken"}`},
+			{MessageId: "3", Body: `not json`},
+			{MessageId: "4", Body: `{"organisation": "stylelia", "name": "nginx", "tools": ["chefstyle"]}`},
+			{MessageId: "5", Body: `{"organisation": "stylelia", "name": "nginx"}`},
+		},
+	}
+
+	response := handler.handleSQS(context.Background(), sqsEvent)
+	assert.Equal(t, []events.SQSBatchItemFailure{
+		{ItemIdentifier: "2"},
+		{ItemIdentifier: "3"},
+		{ItemIdentifier: "4"},
+	}, response.BatchItemFailures)
+}
+
+func TestHandleSQSAllSucceeded(t *testing.T) {
+	analyseRepo := func(h *Handler, ctx context.Context, event Event) (Outcome, *DryRun, error) {
+		return Skipped, nil, nil
+	}
+
+	handler := NewHandler(nil, logger.NewLogger(logger.DEBUG, false), Config{})
+	handler.WorkingDir = t.TempDir()
+	handler.analyseRepo = analyseRepo
+
+	sqsEvent := events.SQSEvent{
+		Records: []events.SQSMessage{
+			{MessageId: "1", Body: `{"organisation": "stylelia", "name": "snort"}`},
+		},
+	}
+
+	// An empty list, rather than null, tells Lambda the whole batch succeeded
+	response := handler.handleSQS(context.Background(), sqsEvent)
+	assert.NotNil(t, response.BatchItemFailures)
+	assert.Empty(t, response.BatchItemFailures)
+}
@@ -0,0 +1,49 @@
+package analyser
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v39/github"
+)
+
+const commitStatusContext string = "stylelia/cookstyle"
+
+// Providers that can set a status on a commit
+type statusPublisher interface {
+	SetCommitStatus(ctx context.Context, repo Repository, status *github.RepoStatus) error
+}
+
+// Sets the status of repo.LatestCommit, failing with a link to change when
+// Cookstyle found offences
+func publishCommitStatus(ctx context.Context, provider Provider, repo Repository, cookstyleVersion string, result CookstyleCheck, change *ChangeRequest) error {
+	publisher, ok := provider.(statusPublisher)
+	if !ok {
+		return fmt.Errorf("commit statuses are only supported on GitHub")
+	}
+
+	return publisher.SetCommitStatus(ctx, repo, commitStatus(cookstyleVersion, result, change))
+}
+
+func commitStatus(cookstyleVersion string, result CookstyleCheck, change *ChangeRequest) *github.RepoStatus {
+	status := &github.RepoStatus{Context: github.String(commitStatusContext)}
+	if result.Summary.OffenseCount == 0 {
+		status.State = github.String("success")
+		status.Description = github.String(fmt.Sprintf("Cookstyle %s found no offences", cookstyleVersion))
+		return status
+	}
+
+	status.State = github.String("failure")
+	description := fmt.Sprintf("Cookstyle %s found %d offences", cookstyleVersion, result.Summary.OffenseCount)
+	if change != nil {
+		description += ", fixed in the Stylelia PR"
+		status.TargetURL = github.String(change.URL)
+	}
+	status.Description = github.String(description)
+	return status
+}
+
+func (g *githubProvider) SetCommitStatus(ctx context.Context, repo Repository, status *github.RepoStatus) error {
+	_, _, err := g.client.Repositories.CreateStatus(ctx, repo.Org, repo.Name, repo.LatestCommit, status)
+	return githubError(err)
+}
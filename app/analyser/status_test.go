@@ -0,0 +1,71 @@
+package analyser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishCommitStatus(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRepo("stylelia", "snort", "main")
+	repo.LatestCommit = "b64d5bae3cee6da8c305c0f46f678914cb22e483"
+
+	testCases := []struct {
+		desc     string
+		result   CookstyleCheck
+		change   *ChangeRequest
+		expected map[string]interface{}
+	}{
+		{
+			desc:   "Succeeds without offences",
+			result: CookstyleCheck{},
+			expected: map[string]interface{}{
+				"context":     "stylelia/cookstyle",
+				"state":       "success",
+				"description": "Cookstyle 7.25.0 found no offences",
+			},
+		},
+		{
+			desc:   "Fails with the offence count and links the PR",
+			result: CookstyleCheck{Summary: Summary{OffenseCount: 3}},
+			change: &ChangeRequest{Number: 4, URL: "https://github.com/stylelia/snort/pull/4"},
+			expected: map[string]interface{}{
+				"context":     "stylelia/cookstyle",
+				"state":       "failure",
+				"description": "Cookstyle 7.25.0 found 3 offences, fixed in the Stylelia PR",
+				"target_url":  "https://github.com/stylelia/snort/pull/4",
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			var created map[string]interface{}
+			mux := http.NewServeMux()
+			mux.HandleFunc("/repos/stylelia/snort/statuses/b64d5bae3cee6da8c305c0f46f678914cb22e483", func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+				fmt.Fprint(w, `{}`)
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+			provider := &githubProvider{client: newTestGithubClient(server)}
+
+			err := publishCommitStatus(ctx, provider, repo, "7.25.0", tC.result, tC.change)
+			assert.NoError(t, err)
+			assert.Equal(t, tC.expected, created)
+		})
+	}
+
+	t.Run("Is only supported on GitHub", func(t *testing.T) {
+		gitea := newGiteaProvider(&http.Client{}, GiteaServer{URL: "https://gitea.example.com", Token: "gtToken"})
+
+		err := publishCommitStatus(ctx, gitea, repo, "7.25.0", CookstyleCheck{}, nil)
+		assert.EqualError(t, err, "commit statuses are only supported on GitHub")
+	})
+}
@@ -0,0 +1,255 @@
+package analyser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v39/github"
+)
+
+const trackingIssueTitle string = "Stylelia: Cookstyle offences to fix by hand"
+
+// Hidden in the body so the issue is found again whatever it's been renamed to
+const trackingIssueMarker string = "<!-- stylelia:cookstyle-offences -->"
+
+// GitHub refuses bodies over 65536 characters, leave room for the last line
+const maxTrackingIssueBody int = 60000
+
+// An issue Stylelia keeps up to date
+type trackedIssue struct {
+	Number int
+	URL    string
+	Body   string
+	Open   bool
+}
+
+// Providers that can keep an issue listing the offences Cookstyle couldn't correct
+type issueTracker interface {
+	// Returns ErrNotFound if there's no issue number
+	GetIssue(ctx context.Context, repo Repository, number int) (*trackedIssue, error)
+	// Returns the latest issue, open or closed, whose body contains marker, nil if there isn't one
+	FindIssue(ctx context.Context, repo Repository, marker string) (*trackedIssue, error)
+	OpenIssue(ctx context.Context, repo Repository, title, body string) (*trackedIssue, error)
+	// Replaces the body and reopens the issue if it was closed
+	UpdateIssue(ctx context.Context, repo Repository, issue *trackedIssue, body string) error
+	// Leaves comment on the issue and closes it
+	CloseIssue(ctx context.Context, repo Repository, issue *trackedIssue, comment string) error
+}
+
+// Keeps one issue in repo listing the offences Cookstyle couldn't correct,
+// closing it once there are none left. The issue is only touched when the
+// offences change, so someone closing it keeps it closed until they do.
+func (h *Handler) trackOffences(ctx context.Context, provider Provider, repo Repository, cookstyleVersion string, result CookstyleCheck) error {
+	tracker, ok := provider.(issueTracker)
+	if !ok {
+		return fmt.Errorf("tracking issues are only supported on GitHub")
+	}
+
+	issue, err := h.trackedIssue(ctx, tracker, repo)
+	if err != nil {
+		return err
+	}
+
+	body := trackingIssueBody(cookstyleVersion, result)
+	switch {
+	case body == "" && (issue == nil || !issue.Open):
+		return nil
+
+	case body == "":
+		comment := fmt.Sprintf("Cookstyle %s found nothing left to fix by hand, closing.", cookstyleVersion)
+		err = tracker.CloseIssue(ctx, repo, issue, comment)
+		if err != nil {
+			return err
+		}
+		h.Log.Infof("Tracking issue closed! %s", issue.URL)
+
+	case issue == nil:
+		issue, err = tracker.OpenIssue(ctx, repo, trackingIssueTitle, body)
+		if err != nil {
+			return err
+		}
+		h.Log.Infof("Tracking issue opened! %s", issue.URL)
+
+		err = h.Store.UpdateTrackingIssue(ctx, repo.Org, repo.Name, Cookstyle, issue.Number)
+		if err != nil {
+			return err
+		}
+
+	case issue.Body != body:
+		err = tracker.UpdateIssue(ctx, repo, issue, body)
+		if err != nil {
+			return err
+		}
+		h.Log.Infof("Tracking issue updated! %s", issue.URL)
+	}
+
+	return nil
+}
+
+// Returns the tracking issue of repo, nil if there isn't one. The number
+// stored for it is tried first, every issue is only searched when that's
+// missing or no longer the tracking issue, e.g. it was deleted.
+func (h *Handler) trackedIssue(ctx context.Context, tracker issueTracker, repo Repository) (*trackedIssue, error) {
+	number, err := h.Store.GetTrackingIssue(ctx, repo.Org, repo.Name, Cookstyle)
+	if err != nil {
+		return nil, err
+	}
+
+	if number != 0 {
+		issue, err := tracker.GetIssue(ctx, repo, number)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		if err == nil && strings.Contains(issue.Body, trackingIssueMarker) {
+			return issue, nil
+		}
+		h.Log.Debugf("Issue #%d no longer tracks offences, searching for the tracking issue", number)
+	}
+
+	issue, err := tracker.FindIssue(ctx, repo, trackingIssueMarker)
+	if err != nil || issue == nil {
+		return issue, err
+	}
+
+	return issue, h.Store.UpdateTrackingIssue(ctx, repo.Org, repo.Name, Cookstyle, issue.Number)
+}
+
+// Lists the offences Cookstyle didn't correct by cop and then by file, empty when there are none
+func trackingIssueBody(cookstyleVersion string, result CookstyleCheck) string {
+	// cop, then path, then the offences in it
+	byCop := make(map[string]map[string][]Offenses)
+	count := 0
+	for _, file := range result.Files {
+		for _, offense := range file.Offenses {
+			if offense.Corrected {
+				continue
+			}
+			if byCop[offense.CopName] == nil {
+				byCop[offense.CopName] = make(map[string][]Offenses)
+			}
+			byCop[offense.CopName][file.Path] = append(byCop[offense.CopName][file.Path], offense)
+			count++
+		}
+	}
+	if count == 0 {
+		return ""
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "%s\nCookstyle %s found %d offences it can't correct, they need fixing by hand. Stylelia keeps this issue up to date and closes it once they're gone.\n", trackingIssueMarker, cookstyleVersion, count)
+
+	listed := 0
+	for _, cop := range sortedCops(byCop) {
+		var section strings.Builder
+		fmt.Fprintf(&section, "\n### %s\n", cop)
+
+		paths := make([]string, 0, len(byCop[cop]))
+		for path := range byCop[cop] {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		offences := 0
+		for _, path := range paths {
+			fmt.Fprintf(&section, "\n`%s`\n", path)
+			for _, offense := range byCop[cop][path] {
+				// Offenses about the whole file have no line
+				if offense.Location.StartLine == 0 {
+					fmt.Fprintf(&section, "- %s\n", offense.Message)
+				} else {
+					fmt.Fprintf(&section, "- Line %d: %s\n", offense.Location.StartLine, offense.Message)
+				}
+				offences++
+			}
+		}
+
+		if body.Len()+section.Len() > maxTrackingIssueBody {
+			break
+		}
+		body.WriteString(section.String())
+		listed += offences
+	}
+
+	if listed < count {
+		fmt.Fprintf(&body, "\nAnd %d more offences that don't fit in this issue.\n", count-listed)
+	}
+
+	return body.String()
+}
+
+func sortedCops(byCop map[string]map[string][]Offenses) []string {
+	cops := make([]string, 0, len(byCop))
+	for cop := range byCop {
+		cops = append(cops, cop)
+	}
+	sort.Strings(cops)
+
+	return cops
+}
+
+func (g *githubProvider) GetIssue(ctx context.Context, repo Repository, number int) (*trackedIssue, error) {
+	issue, _, err := g.client.Issues.Get(ctx, repo.Org, repo.Name, number)
+	if err != nil {
+		return nil, githubError(err)
+	}
+
+	return githubIssue(issue), nil
+}
+
+func (g *githubProvider) FindIssue(ctx context.Context, repo Repository, marker string) (*trackedIssue, error) {
+	opt := &github.IssueListByRepoOptions{State: "all", Sort: "created", Direction: "desc", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		issues, response, err := g.client.Issues.ListByRepo(ctx, repo.Org, repo.Name, opt)
+		if err != nil {
+			return nil, githubError(err)
+		}
+
+		for _, issue := range issues {
+			if !issue.IsPullRequest() && strings.Contains(issue.GetBody(), marker) {
+				return githubIssue(issue), nil
+			}
+		}
+
+		if response.NextPage == 0 {
+			return nil, nil
+		}
+		opt.Page = response.NextPage
+	}
+}
+
+func (g *githubProvider) OpenIssue(ctx context.Context, repo Repository, title, body string) (*trackedIssue, error) {
+	issue, _, err := g.client.Issues.Create(ctx, repo.Org, repo.Name, &github.IssueRequest{Title: &title, Body: &body})
+	if err != nil {
+		return nil, githubError(err)
+	}
+
+	return githubIssue(issue), nil
+}
+
+func (g *githubProvider) UpdateIssue(ctx context.Context, repo Repository, issue *trackedIssue, body string) error {
+	request := &github.IssueRequest{Body: &body, State: github.String("open")}
+	_, _, err := g.client.Issues.Edit(ctx, repo.Org, repo.Name, issue.Number, request)
+	return githubError(err)
+}
+
+func (g *githubProvider) CloseIssue(ctx context.Context, repo Repository, issue *trackedIssue, comment string) error {
+	_, _, err := g.client.Issues.CreateComment(ctx, repo.Org, repo.Name, issue.Number, &github.IssueComment{Body: &comment})
+	if err != nil {
+		return githubError(err)
+	}
+
+	_, _, err = g.client.Issues.Edit(ctx, repo.Org, repo.Name, issue.Number, &github.IssueRequest{State: github.String("closed")})
+	return githubError(err)
+}
+
+func githubIssue(issue *github.Issue) *trackedIssue {
+	return &trackedIssue{
+		Number: issue.GetNumber(),
+		URL:    issue.GetHTMLURL(),
+		Body:   issue.GetBody(),
+		Open:   issue.GetState() == "open",
+	}
+}
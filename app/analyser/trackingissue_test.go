@@ -0,0 +1,244 @@
+package analyser
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/youshy/logger"
+)
+
+var uncorrectedResult = CookstyleCheck{
+	Files: []Files{
+		{
+			Path: "recipes/default.rb",
+			Offenses: []Offenses{
+				{CopName: "Chef/Style/CommentFormat", Message: "Properly format header comments", Corrected: true, Location: Location{StartLine: 1}},
+				{CopName: "Chef/Deprecations/NodeSet", Message: "Do not use node.set", Location: Location{StartLine: 4}},
+				{CopName: "Chef/Deprecations/NodeSet", Message: "Do not use node.set", Location: Location{StartLine: 9}},
+			},
+		},
+		{
+			Path: "metadata.rb",
+			Offenses: []Offenses{
+				{CopName: "Chef/Sharing/IncludePropertyDescriptions", Message: "Resources should include description fields"},
+				{CopName: "Chef/Deprecations/NodeSet", Message: "Do not use node.set", Location: Location{StartLine: 2}},
+			},
+		},
+	},
+}
+
+func TestTrackingIssueBody(t *testing.T) {
+	t.Run("Lists the uncorrected offences by cop and file", func(t *testing.T) {
+		expected := `<!-- stylelia:cookstyle-offences -->
+Cookstyle 7.25.0 found 4 offences it can't correct, they need fixing by hand. Stylelia keeps this issue up to date and closes it once they're gone.
+
+### Chef/Deprecations/NodeSet
+
+` + "`metadata.rb`" + `
+- Line 2: Do not use node.set
+
+` + "`recipes/default.rb`" + `
+- Line 4: Do not use node.set
+- Line 9: Do not use node.set
+
+### Chef/Sharing/IncludePropertyDescriptions
+
+` + "`metadata.rb`" + `
+- Resources should include description fields
+`
+		assert.Equal(t, expected, trackingIssueBody("7.25.0", uncorrectedResult))
+	})
+
+	t.Run("Is empty when everything was corrected", func(t *testing.T) {
+		result := CookstyleCheck{Files: []Files{{Path: "metadata.rb", Offenses: []Offenses{{CopName: "Chef/Style/CommentFormat", Corrected: true}}}}}
+		assert.Equal(t, "", trackingIssueBody("7.25.0", result))
+	})
+
+	t.Run("Leaves out the offences that don't fit", func(t *testing.T) {
+		var files []Files
+		for i := 0; i < 2000; i++ {
+			offense := Offenses{CopName: fmt.Sprintf("Chef/Style/Cop%04d", i), Message: "Something that has to be fixed by hand", Location: Location{StartLine: 1}}
+			files = append(files, Files{Path: "recipes/default.rb", Offenses: []Offenses{offense}})
+		}
+
+		body := trackingIssueBody("7.25.0", CookstyleCheck{Files: files})
+		assert.LessOrEqual(t, len(body), maxTrackingIssueBody+100)
+		assert.Regexp(t, `\nAnd \d+ more offences that don't fit in this issue\.\n$`, body)
+	})
+}
+
+// Records what happens to the tracking issue
+type fakeIssueTracker struct {
+	Provider
+	issue *trackedIssue
+	// Times every issue was searched
+	searched int
+	opened   string
+	updated  string
+	closed   string
+}
+
+func (f *fakeIssueTracker) GetIssue(ctx context.Context, repo Repository, number int) (*trackedIssue, error) {
+	if f.issue == nil || f.issue.Number != number {
+		return nil, ErrNotFound
+	}
+	return f.issue, nil
+}
+
+func (f *fakeIssueTracker) FindIssue(ctx context.Context, repo Repository, marker string) (*trackedIssue, error) {
+	f.searched++
+	return f.issue, nil
+}
+
+func (f *fakeIssueTracker) OpenIssue(ctx context.Context, repo Repository, title, body string) (*trackedIssue, error) {
+	f.opened = body
+	return &trackedIssue{Number: 3, URL: "https://github.com/stylelia/snort/issues/3", Body: body, Open: true}, nil
+}
+
+func (f *fakeIssueTracker) UpdateIssue(ctx context.Context, repo Repository, issue *trackedIssue, body string) error {
+	f.updated = body
+	return nil
+}
+
+func (f *fakeIssueTracker) CloseIssue(ctx context.Context, repo Repository, issue *trackedIssue, comment string) error {
+	f.closed = comment
+	return nil
+}
+
+func TestHandlerTrackOffences(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRepo("stylelia", "snort", "main")
+	handler := Handler{Log: logger.NewLogger(logger.DEBUG, false), Store: newFakeRunStore()}
+	body := trackingIssueBody("7.25.0", uncorrectedResult)
+
+	t.Run("Opens an issue when there isn't one and remembers it", func(t *testing.T) {
+		store := newFakeRunStore()
+		handler := Handler{Log: logger.NewLogger(logger.DEBUG, false), Store: store}
+		tracker := &fakeIssueTracker{}
+		err := handler.trackOffences(ctx, tracker, repo, "7.25.0", uncorrectedResult)
+		assert.NoError(t, err)
+		assert.Equal(t, body, tracker.opened)
+		assert.Equal(t, 3, store.issues["stylelia/snort/Cookstyle"])
+	})
+
+	t.Run("Gets the issue it remembers without searching", func(t *testing.T) {
+		store := newFakeRunStore()
+		store.issues["stylelia/snort/Cookstyle"] = 3
+		handler := Handler{Log: logger.NewLogger(logger.DEBUG, false), Store: store}
+		tracker := &fakeIssueTracker{issue: &trackedIssue{Number: 3, Body: body, Open: true}}
+		err := handler.trackOffences(ctx, tracker, repo, "7.25.0", uncorrectedResult)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, tracker.searched)
+		assert.Empty(t, tracker.opened)
+	})
+
+	t.Run("Searches when the issue it remembers is gone", func(t *testing.T) {
+		store := newFakeRunStore()
+		store.issues["stylelia/snort/Cookstyle"] = 9
+		handler := Handler{Log: logger.NewLogger(logger.DEBUG, false), Store: store}
+		tracker := &fakeIssueTracker{issue: &trackedIssue{Number: 3, Body: body, Open: true}}
+		err := handler.trackOffences(ctx, tracker, repo, "7.25.0", uncorrectedResult)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, tracker.searched)
+		assert.Equal(t, 3, store.issues["stylelia/snort/Cookstyle"])
+	})
+
+	t.Run("Searches when the issue it remembers no longer has the marker", func(t *testing.T) {
+		store := newFakeRunStore()
+		store.issues["stylelia/snort/Cookstyle"] = 3
+		handler := Handler{Log: logger.NewLogger(logger.DEBUG, false), Store: store}
+		tracker := &fakeIssueTracker{issue: &trackedIssue{Number: 3, Body: "Rewritten by hand", Open: true}}
+		err := handler.trackOffences(ctx, tracker, repo, "7.25.0", uncorrectedResult)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, tracker.searched)
+	})
+
+	t.Run("Updates the issue when the offences change", func(t *testing.T) {
+		tracker := &fakeIssueTracker{issue: &trackedIssue{Number: 3, Body: "older offences", Open: true}}
+		err := handler.trackOffences(ctx, tracker, repo, "7.25.0", uncorrectedResult)
+		assert.NoError(t, err)
+		assert.Equal(t, body, tracker.updated)
+		assert.Empty(t, tracker.opened)
+	})
+
+	t.Run("Leaves an issue listing the same offences alone, even when it was closed", func(t *testing.T) {
+		tracker := &fakeIssueTracker{issue: &trackedIssue{Number: 3, Body: body}}
+		err := handler.trackOffences(ctx, tracker, repo, "7.25.0", uncorrectedResult)
+		assert.NoError(t, err)
+		assert.Empty(t, tracker.opened)
+		assert.Empty(t, tracker.updated)
+	})
+
+	t.Run("Closes the issue once nothing is left", func(t *testing.T) {
+		tracker := &fakeIssueTracker{issue: &trackedIssue{Number: 3, Body: body, Open: true}}
+		err := handler.trackOffences(ctx, tracker, repo, "7.26.0", CookstyleCheck{})
+		assert.NoError(t, err)
+		assert.Equal(t, "Cookstyle 7.26.0 found nothing left to fix by hand, closing.", tracker.closed)
+	})
+
+	t.Run("Does nothing without offences or an open issue", func(t *testing.T) {
+		tracker := &fakeIssueTracker{issue: &trackedIssue{Number: 3, Body: body}}
+		err := handler.trackOffences(ctx, tracker, repo, "7.26.0", CookstyleCheck{})
+		assert.NoError(t, err)
+		assert.Empty(t, tracker.closed)
+	})
+
+	t.Run("Is only supported on GitHub", func(t *testing.T) {
+		err := handler.trackOffences(ctx, &gitlabProvider{}, repo, "7.25.0", uncorrectedResult)
+		assert.EqualError(t, err, "tracking issues are only supported on GitHub")
+	})
+}
+
+func TestGithubFindIssue(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRepo("stylelia", "snort", "main")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/stylelia/snort/issues", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "all", r.URL.Query().Get("state"))
+		assert.Equal(t, "desc", r.URL.Query().Get("direction"))
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", `<`+"http://"+r.Host+r.URL.Path+`?page=2>; rel="next"`)
+			fmt.Fprint(w, `[
+				{"number": 6, "body": "Bump the version"},
+				{"number": 5, "body": "<!-- stylelia:cookstyle-offences -->", "pull_request": {"url": "https://api.github.com/repos/stylelia/snort/pulls/5"}}
+			]`)
+			return
+		}
+		fmt.Fprint(w, `[{"number": 3, "html_url": "https://github.com/stylelia/snort/issues/3", "state": "closed", "body": "<!-- stylelia:cookstyle-offences -->\nOffences"}]`)
+	})
+	mux.HandleFunc("/repos/stylelia/snort/issues/3", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"number": 3, "html_url": "https://github.com/stylelia/snort/issues/3", "state": "open", "body": "<!-- stylelia:cookstyle-offences -->\nOffences"}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider := &githubProvider{client: newTestGithubClient(server)}
+
+	t.Run("Gets an issue by number", func(t *testing.T) {
+		issue, err := provider.GetIssue(ctx, repo, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, &trackedIssue{Number: 3, URL: "https://github.com/stylelia/snort/issues/3", Body: "<!-- stylelia:cookstyle-offences -->\nOffences", Open: true}, issue)
+	})
+
+	t.Run("Returns ErrNotFound for a missing issue", func(t *testing.T) {
+		_, err := provider.GetIssue(ctx, repo, 9)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("Returns the latest issue with the marker, skipping PRs", func(t *testing.T) {
+		issue, err := provider.FindIssue(ctx, repo, trackingIssueMarker)
+		assert.NoError(t, err)
+		assert.Equal(t, &trackedIssue{Number: 3, URL: "https://github.com/stylelia/snort/issues/3", Body: "<!-- stylelia:cookstyle-offences -->\nOffences"}, issue)
+	})
+
+	t.Run("Returns nothing without an issue", func(t *testing.T) {
+		issue, err := provider.FindIssue(ctx, repo, "<!-- something else -->")
+		assert.NoError(t, err)
+		assert.Nil(t, issue)
+	})
+}
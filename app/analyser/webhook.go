@@ -0,0 +1,277 @@
+package analyser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v39/github"
+	"go.uber.org/zap"
+)
+
+const (
+	pushEvent         string = "push"
+	pingEvent         string = "ping"
+	issueCommentEvent string = "issue_comment"
+)
+
+// Receives GitHub webhooks and runs the analyser against the repository
+// whenever its default branch is pushed to, or a command is left on one of
+// Stylelia's Pull Requests
+type WebhookServer struct {
+	Secret []byte
+	Log    *zap.SugaredLogger
+	// Runs the analyser for an event, swapped out in tests
+	Run func(context.Context, Event) (Result, error)
+	// Carries out the command in a comment, swapped out in tests
+	Command func(context.Context, Comment) error
+	// Most runs at once, defaultConcurrency when not set
+	Concurrency int
+
+	queue     *runQueue
+	queueOnce sync.Once
+}
+
+func NewWebhookServer(handler *Handler, secret []byte, log *zap.SugaredLogger) *WebhookServer {
+	return &WebhookServer{
+		Secret:  secret,
+		Log:     log,
+		Run:     handler.handle,
+		Command: handler.command,
+	}
+}
+
+// Starts an HTTP server receiving webhooks on addr
+func ServeWebhooks(addr string) error {
+	config, err := LoadConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	err = config.ValidateServer()
+	if err != nil {
+		return err
+	}
+
+	handler, err := newHandlerFromConfig(config)
+	if err != nil {
+		return err
+	}
+	server := NewWebhookServer(&handler, []byte(config.WebhookSecret), handler.Log)
+
+	mux := http.NewServeMux()
+	mux.Handle("/webhook", server)
+
+	handler.Log.Infof("Listening for webhooks on %s", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+func (s *WebhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	payload, err := s.validatePayload(r)
+	if err != nil {
+		s.Log.Warnf("Rejected webhook: %v", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	switch github.WebHookType(r) {
+	case pingEvent:
+		w.WriteHeader(http.StatusOK)
+		return
+	case issueCommentEvent:
+		s.serveComment(w, payload)
+		return
+	case pushEvent:
+	default:
+		s.Log.Debugf("Ignoring %q webhook", github.WebHookType(r))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var push github.PushEvent
+	err = json.Unmarshal(payload, &push)
+	if err != nil {
+		s.Log.Warnf("Unable to decode push webhook: %v", err)
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	event, ok := eventFromPush(push)
+	if !ok {
+		s.Log.Debugf("Ignoring push to %s on %s", push.GetRef(), push.GetRepo().GetFullName())
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// GitHub gives up on a delivery after 10 seconds, far shorter than a
+	// clone and cookstyle run, so acknowledge first and process afterwards
+	go s.runQueue().run(event.Organisation, event.Name, func() {
+		_, err := s.Run(context.Background(), event)
+		if err != nil {
+			s.Log.Errorf("Unable to process push to %s/%s: %v", event.Organisation, event.Name, err)
+		}
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *WebhookServer) serveComment(w http.ResponseWriter, payload []byte) {
+	var event github.IssueCommentEvent
+	err := json.Unmarshal(payload, &event)
+	if err != nil {
+		s.Log.Warnf("Unable to decode issue_comment webhook: %v", err)
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	comment, ok := commentFromEvent(event)
+	if !ok {
+		s.Log.Debugf("Ignoring comment %d on %s", event.GetComment().GetID(), event.GetRepo().GetFullName())
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// Commands can run the analyser, so they're acknowledged first like pushes
+	go s.runQueue().run(comment.Organisation, comment.Name, func() {
+		err := s.Command(context.Background(), comment)
+		if err != nil {
+			s.Log.Errorf("Unable to process comment on %s/%s#%d: %v", comment.Organisation, comment.Name, comment.Number, err)
+		}
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *WebhookServer) runQueue() *runQueue {
+	s.queueOnce.Do(func() {
+		concurrency := s.Concurrency
+		if concurrency < 1 {
+			concurrency = defaultConcurrency
+		}
+		s.queue = newRunQueue(concurrency)
+	})
+
+	return s.queue
+}
+
+// Bounds the runs started by webhooks and runs those for the same repository
+// one after the other, as they'd clone into the same working directory and
+// race to push the same branch
+type runQueue struct {
+	slots chan struct{}
+
+	mu    sync.Mutex
+	repos map[string]*repoLock
+}
+
+// Held while a repository runs, dropped once nothing is waiting on it
+type repoLock struct {
+	sync.Mutex
+	waiting int
+}
+
+func newRunQueue(concurrency int) *runQueue {
+	return &runQueue{
+		slots: make(chan struct{}, concurrency),
+		repos: make(map[string]*repoLock),
+	}
+}
+
+// Calls fn once no other run for org/name is going and a slot is free
+func (q *runQueue) run(org, name string, fn func()) {
+	// GitHub names are case insensitive
+	key := strings.ToLower(org + "/" + name)
+
+	q.mu.Lock()
+	lock, ok := q.repos[key]
+	if !ok {
+		lock = &repoLock{}
+		q.repos[key] = lock
+	}
+	lock.waiting++
+	q.mu.Unlock()
+
+	// The repository first, so a queue of runs for one repository never
+	// holds more than one slot
+	lock.Lock()
+	q.slots <- struct{}{}
+	defer func() {
+		<-q.slots
+		lock.Unlock()
+
+		q.mu.Lock()
+		lock.waiting--
+		if lock.waiting == 0 {
+			delete(q.repos, key)
+		}
+		q.mu.Unlock()
+	}()
+
+	fn()
+}
+
+// Checks the X-Hub-Signature-256 HMAC and returns the JSON payload
+func (s *WebhookServer) validatePayload(r *http.Request) ([]byte, error) {
+	// go-github skips the check entirely without a secret, we never want that
+	if len(s.Secret) == 0 {
+		return nil, errors.New("no webhook secret configured")
+	}
+
+	signature := r.Header.Get(github.SHA256SignatureHeader)
+	if signature == "" {
+		return nil, fmt.Errorf("missing %s header", github.SHA256SignatureHeader)
+	}
+
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+
+	return github.ValidatePayloadFromBody(contentType, r.Body, signature, s.Secret)
+}
+
+// Builds the event for a push, returns false if the push is not to the default branch
+func eventFromPush(push github.PushEvent) (Event, bool) {
+	repo := push.GetRepo()
+	if push.GetDeleted() || push.GetRef() != "refs/heads/"+repo.GetDefaultBranch() {
+		return Event{}, false
+	}
+
+	return Event{
+		Organisation: repo.GetOwner().GetLogin(),
+		Name:         repo.GetName(),
+	}, true
+}
+
+// Builds the comment for a new comment on a Pull Request mentioning Stylelia,
+// returns false for anything else. Bots are ignored so Stylelia never answers itself.
+func commentFromEvent(event github.IssueCommentEvent) (Comment, bool) {
+	issue := event.GetIssue()
+	comment := event.GetComment()
+	if event.GetAction() != "created" || !issue.IsPullRequest() || comment.GetUser().GetType() == "Bot" {
+		return Comment{}, false
+	}
+
+	_, err := parseCommand(comment.GetBody())
+	if errors.Is(err, errNoCommand) {
+		return Comment{}, false
+	}
+
+	repo := event.GetRepo()
+	return Comment{
+		Organisation: repo.GetOwner().GetLogin(),
+		Name:         repo.GetName(),
+		Number:       issue.GetNumber(),
+		User:         comment.GetUser().GetLogin(),
+		Body:         comment.GetBody(),
+	}, true
+}
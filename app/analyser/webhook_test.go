@@ -0,0 +1,249 @@
+package analyser
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/youshy/logger"
+)
+
+const webhookSecret string = "ItsATrap"
+
+const pushPayload string = `{
+	"ref": "refs/heads/%s",
+	"repository": {
+		"name": "snort",
+		"full_name": "stylelia/snort",
+		"default_branch": "main",
+		"owner": {"login": "stylelia"}
+	}
+}`
+
+const commentPayload string = `{
+	"action": "%s",
+	"issue": {"number": 7, "pull_request": {"url": "https://api.github.com/repos/stylelia/snort/pulls/7"}},
+	"comment": {"id": 1, "body": %q, "user": {"login": "xorima", "type": "%s"}},
+	"repository": {
+		"name": "snort",
+		"full_name": "stylelia/snort",
+		"owner": {"login": "stylelia"}
+	}
+}`
+
+func signPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newWebhookRequest(eventType, signature string, payload []byte) *http.Request {
+	request := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-GitHub-Event", eventType)
+	if signature != "" {
+		request.Header.Set("X-Hub-Signature-256", signature)
+	}
+	return request
+}
+
+func newTestWebhookServer(events chan Event) *WebhookServer {
+	return &WebhookServer{
+		Secret: []byte(webhookSecret),
+		Log:    logger.NewLogger(logger.DEBUG, false),
+		Run: func(ctx context.Context, event Event) (Result, error) {
+			events <- event
+			return Result{}, nil
+		},
+	}
+}
+
+func TestWebhookServer(t *testing.T) {
+	t.Run("A signed push to the default branch runs the analyser", func(t *testing.T) {
+		events := make(chan Event, 1)
+		server := newTestWebhookServer(events)
+		payload := []byte(fmt.Sprintf(pushPayload, "main"))
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, newWebhookRequest("push", signPayload(webhookSecret, payload), payload))
+		assert.Equal(t, http.StatusAccepted, recorder.Code)
+
+		select {
+		case event := <-events:
+			assert.Equal(t, Event{Organisation: "stylelia", Name: "snort"}, event)
+		case <-time.After(time.Second):
+			t.Fatal("analyser was not run")
+		}
+	})
+
+	t.Run("A push to another branch is ignored", func(t *testing.T) {
+		events := make(chan Event, 1)
+		server := newTestWebhookServer(events)
+		payload := []byte(fmt.Sprintf(pushPayload, "stylelia/cookstyle_7.25.6"))
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, newWebhookRequest("push", signPayload(webhookSecret, payload), payload))
+		assert.Equal(t, http.StatusNoContent, recorder.Code)
+		assert.Len(t, events, 0)
+	})
+
+	t.Run("A wrongly signed payload is rejected", func(t *testing.T) {
+		events := make(chan Event, 1)
+		server := newTestWebhookServer(events)
+		payload := []byte(fmt.Sprintf(pushPayload, "main"))
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, newWebhookRequest("push", signPayload("NotTheSecret", payload), payload))
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		assert.Len(t, events, 0)
+	})
+
+	t.Run("An unsigned payload is rejected", func(t *testing.T) {
+		events := make(chan Event, 1)
+		server := newTestWebhookServer(events)
+		payload := []byte(fmt.Sprintf(pushPayload, "main"))
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, newWebhookRequest("push", "", payload))
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+
+	t.Run("A server without a secret rejects everything", func(t *testing.T) {
+		events := make(chan Event, 1)
+		server := newTestWebhookServer(events)
+		server.Secret = nil
+		payload := []byte(fmt.Sprintf(pushPayload, "main"))
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, newWebhookRequest("push", signPayload("", payload), payload))
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+
+	t.Run("A ping is acknowledged", func(t *testing.T) {
+		server := newTestWebhookServer(make(chan Event, 1))
+		payload := []byte(`{"zen": "Keep it logically awesome."}`)
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, newWebhookRequest("ping", signPayload(webhookSecret, payload), payload))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("Only POST is allowed", func(t *testing.T) {
+		server := newTestWebhookServer(make(chan Event, 1))
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/webhook", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+	})
+
+	t.Run("A command on a PR is carried out", func(t *testing.T) {
+		comments := make(chan Comment, 1)
+		server := newTestWebhookServer(make(chan Event, 1))
+		server.Command = func(ctx context.Context, comment Comment) error {
+			comments <- comment
+			return nil
+		}
+		payload := []byte(fmt.Sprintf(commentPayload, "created", "@stylelia rebase", "User"))
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, newWebhookRequest("issue_comment", signPayload(webhookSecret, payload), payload))
+		assert.Equal(t, http.StatusAccepted, recorder.Code)
+
+		select {
+		case comment := <-comments:
+			expected := Comment{Organisation: "stylelia", Name: "snort", Number: 7, User: "xorima", Body: "@stylelia rebase"}
+			assert.Equal(t, expected, comment)
+		case <-time.After(time.Second):
+			t.Fatal("command was not run")
+		}
+	})
+
+	testCases := []struct {
+		desc    string
+		payload string
+	}{
+		{desc: "A comment without a command is ignored", payload: fmt.Sprintf(commentPayload, "created", "LGTM", "User")},
+		{desc: "An edited comment is ignored", payload: fmt.Sprintf(commentPayload, "edited", "@stylelia rebase", "User")},
+		{desc: "A comment from a bot is ignored", payload: fmt.Sprintf(commentPayload, "created", "@stylelia rebase", "Bot")},
+		{desc: "A comment on an issue is ignored", payload: `{"action": "created", "issue": {"number": 7}, "comment": {"body": "@stylelia rebase"}}`},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			server := newTestWebhookServer(make(chan Event, 1))
+			server.Command = func(ctx context.Context, comment Comment) error {
+				t.Error("command was run")
+				return nil
+			}
+			payload := []byte(tC.payload)
+
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, newWebhookRequest("issue_comment", signPayload(webhookSecret, payload), payload))
+			assert.Equal(t, http.StatusNoContent, recorder.Code)
+		})
+	}
+}
+
+func TestRunQueue(t *testing.T) {
+	// Starts a run that records it started and waits to be released
+	start := func(queue *runQueue, org, name string, started chan<- string, release <-chan struct{}) {
+		go queue.run(org, name, func() {
+			started <- org + "/" + name
+			<-release
+		})
+	}
+	// Returns the next run to start, empty if none starts soon
+	next := func(started <-chan string) string {
+		select {
+		case repo := <-started:
+			return repo
+		case <-time.After(50 * time.Millisecond):
+			return ""
+		}
+	}
+
+	t.Run("Runs for the same repository wait for each other", func(t *testing.T) {
+		queue := newRunQueue(4)
+		started := make(chan string)
+		first := make(chan struct{})
+		second := make(chan struct{})
+		defer close(second)
+
+		start(queue, "stylelia", "snort", started, first)
+		assert.Equal(t, "stylelia/snort", next(started))
+		start(queue, "Stylelia", "Snort", started, second)
+		assert.Empty(t, next(started))
+
+		close(first)
+		assert.Equal(t, "Stylelia/Snort", next(started))
+	})
+
+	t.Run("Only so many repositories run at once", func(t *testing.T) {
+		queue := newRunQueue(2)
+		started := make(chan string)
+		release := make(chan struct{})
+
+		start(queue, "stylelia", "snort", started, release)
+		start(queue, "stylelia", "nginx", started, release)
+		assert.NotEmpty(t, next(started))
+		assert.NotEmpty(t, next(started))
+		start(queue, "stylelia", "java", started, release)
+		assert.Empty(t, next(started))
+
+		close(release)
+		assert.Equal(t, "stylelia/java", next(started))
+	})
+
+	t.Run("Forgets repositories once nothing is waiting on them", func(t *testing.T) {
+		queue := newRunQueue(1)
+		queue.run("stylelia", "snort", func() {})
+		assert.Empty(t, queue.repos)
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/styleila/analyser/app/analyser"
+	"gopkg.in/yaml.v3"
+)
+
+const usage string = `Usage:
+  stylelia run --org <organisation> --repo <repository> [flags]
+  stylelia scan --org <organisation> [flags]
+  stylelia release [flags]
+
+Settings are taken from flags first, then the --config file, then the environment.
+The config file holds both the settings below and the deployment config such as
+redis_host and github_token, it defaults to $STYLELIA_CONFIG.
+
+Flags:
+`
+
+func main() {
+	event, configPath, err := parseArgs(os.Args[1:], os.Stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if configPath == "" {
+		configPath = os.Getenv("STYLELIA_CONFIG")
+	}
+	config, err := analyser.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	result, err := analyser.Run(context.Background(), config, event)
+	fmt.Print(result.Summary())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Builds the event for a command line, returning it with the path of the config file if one was given
+func parseArgs(args []string, output io.Writer) (analyser.Event, string, error) {
+	var event analyser.Event
+
+	if len(args) == 0 {
+		fmt.Fprint(output, usage)
+		return event, "", errors.New("no command given")
+	}
+
+	command := args[0]
+	switch command {
+	case "run", "scan", "release":
+	case "-h", "--help", "help":
+		fmt.Fprint(output, usage)
+		return event, "", flag.ErrHelp
+	default:
+		fmt.Fprint(output, usage)
+		return event, "", fmt.Errorf("unknown command %q", command)
+	}
+
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.Usage = func() {
+		fmt.Fprint(output, usage)
+		fs.PrintDefaults()
+	}
+	config := fs.String("config", "", "YAML file to read settings from, defaults to $STYLELIA_CONFIG")
+	org := fs.String("org", "", "GitHub organisation, defaults to $ORGANISATION")
+	repo := fs.String("repo", "", "Repository name, defaults to $NAME")
+	tools := fs.String("tools", "", "Comma separated tools to run, defaults to all supported tools")
+	force := fs.Bool("force", false, "Run even if the cache says the repository is up to date")
+	dryRun := fs.Bool("dry-run", false, "Don't push, raise Pull Requests or update the cache")
+	marker := fs.String("marker", "", "File marking a repository as a cookbook when scanning, defaults to metadata.rb")
+	concurrency := fs.Int("concurrency", 0, "Number of repositories processed at the same time, defaults to 4")
+
+	err := fs.Parse(args[1:])
+	if err != nil {
+		return event, "", err
+	}
+	if fs.NArg() > 0 {
+		return event, "", fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	if *config != "" {
+		event, err = readConfig(*config)
+		if err != nil {
+			return event, "", err
+		}
+	}
+
+	// Only flags given on the command line override the config file
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "org":
+			event.Organisation = *org
+		case "repo":
+			event.Name = *repo
+		case "tools":
+			event.Tools = strings.Split(*tools, ",")
+		case "force":
+			event.Force = *force
+		case "dry-run":
+			event.DryRun = *dryRun
+		case "marker":
+			event.Marker = *marker
+		case "concurrency":
+			event.Concurrency = *concurrency
+		}
+	})
+	event.Scan = command == "scan"
+	event.Release = command == "release"
+
+	return event, *config, nil
+}
+
+func readConfig(path string) (analyser.Event, error) {
+	var event analyser.Event
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return event, err
+	}
+
+	err = yaml.Unmarshal(content, &event)
+	if err != nil {
+		return event, fmt.Errorf("config: %s: %v", path, err)
+	}
+
+	return event, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/styleila/analyser/app/analyser"
+)
+
+func TestParseArgs(t *testing.T) {
+	t.Run("run takes the repository from flags", func(t *testing.T) {
+		event, _, err := parseArgs([]string{"run", "--org", "stylelia", "--repo", "snort", "--dry-run", "--tools", "cookstyle"}, io.Discard)
+		assert.NoError(t, err)
+		assert.Equal(t, analyser.Event{
+			Organisation: "stylelia",
+			Name:         "snort",
+			Tools:        []string{"cookstyle"},
+			DryRun:       true,
+		}, event)
+	})
+
+	t.Run("scan sets the event to scan the organisation", func(t *testing.T) {
+		event, _, err := parseArgs([]string{"scan", "--org", "stylelia", "--concurrency", "8"}, io.Discard)
+		assert.NoError(t, err)
+		assert.Equal(t, analyser.Event{Organisation: "stylelia", Scan: true, Concurrency: 8}, event)
+	})
+
+	t.Run("release sets the event to fan out a new release", func(t *testing.T) {
+		event, _, err := parseArgs([]string{"release", "--dry-run"}, io.Discard)
+		assert.NoError(t, err)
+		assert.Equal(t, analyser.Event{Release: true, DryRun: true}, event)
+	})
+
+	t.Run("Flags override the config file", func(t *testing.T) {
+		config := filepath.Join(t.TempDir(), "stylelia.yml")
+		err := os.WriteFile(config, []byte("organisation: stylelia\nname: snort\nforce: true\nmarker: Berksfile\n"), 0600)
+		assert.NoError(t, err)
+
+		event, configPath, err := parseArgs([]string{"run", "--config", config, "--repo", "nginx"}, io.Discard)
+		assert.NoError(t, err)
+		assert.Equal(t, config, configPath)
+		assert.Equal(t, analyser.Event{
+			Organisation: "stylelia",
+			Name:         "nginx",
+			Force:        true,
+			Marker:       "Berksfile",
+		}, event)
+	})
+
+	t.Run("A missing config file is an error", func(t *testing.T) {
+		_, _, err := parseArgs([]string{"run", "--config", filepath.Join(t.TempDir(), "missing.yml")}, io.Discard)
+		assert.Error(t, err)
+	})
+
+	t.Run("An unknown command is an error", func(t *testing.T) {
+		_, _, err := parseArgs([]string{"lint"}, io.Discard)
+		assert.EqualError(t, err, `unknown command "lint"`)
+	})
+
+	t.Run("No command is an error", func(t *testing.T) {
+		_, _, err := parseArgs(nil, io.Discard)
+		assert.Error(t, err)
+	})
+
+	t.Run("Stray arguments are an error", func(t *testing.T) {
+		_, _, err := parseArgs([]string{"run", "snort"}, io.Discard)
+		assert.Error(t, err)
+	})
+}
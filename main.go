@@ -1,10 +1,28 @@
 package main
 
 import (
+	"log"
+	"os"
+
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/styleila/analyser/app/analyser"
 )
 
 func main() {
-	lambda.Start(analyser.HandleEvent)
+	switch os.Getenv("STYLELIA_MODE") {
+	case "server":
+		log.Fatal(analyser.ServeWebhooks(getenv("LISTEN_ADDR", ":8080")))
+	case "sqs":
+		lambda.Start(analyser.HandleSQSEvent)
+	default:
+		lambda.Start(analyser.HandleEvent)
+	}
+}
+
+func getenv(key, fallback string) string {
+	value := os.Getenv(key)
+	if len(value) == 0 {
+		return fallback
+	}
+	return value
 }
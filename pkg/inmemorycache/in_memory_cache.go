@@ -4,10 +4,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 )
 
 type InMemoryCache struct {
 	cache map[string]cacheEntry
+	// Latest version seen of each tool
+	globalTools map[string]string
+	// ETag and body of the last response to each request
+	etags map[string]etagEntry
+	// Version of each tool whose PR was closed without merging, by repo and tool
+	declined map[string]string
+	// Version of each tool whose PR was closed to be raised again, by repo and tool
+	recreated map[string]string
+	// Cops turned off in each repo, by repo and tool
+	ignored map[string][]string
+	// Number of the issue tracking what a tool couldn't correct, by repo and tool
+	issues map[string]int
 }
 
 // TODO: Make the cacheEntry support multiple tools for encase
@@ -20,9 +33,20 @@ type cacheEntry struct {
 	toolVersion string
 }
 
+type etagEntry struct {
+	etag string
+	body string
+}
+
 func NewInMemoryCache() *InMemoryCache {
 	cache := make(map[string]cacheEntry)
-	return &InMemoryCache{cache: cache}
+	globalTools := make(map[string]string)
+	etags := make(map[string]etagEntry)
+	declined := make(map[string]string)
+	recreated := make(map[string]string)
+	ignored := make(map[string][]string)
+	issues := make(map[string]int)
+	return &InMemoryCache{cache: cache, globalTools: globalTools, etags: etags, declined: declined, recreated: recreated, ignored: ignored, issues: issues}
 }
 
 func (i *InMemoryCache) UpdateCommitSha(ctx context.Context, githubOrg, repoName, commitSha string) error {
@@ -59,6 +83,102 @@ func (i *InMemoryCache) GetToolVersion(ctx context.Context, githubOrg, repoName,
 	return toolVersion, nil
 }
 
+func (i *InMemoryCache) UpdateDeclinedVersion(ctx context.Context, githubOrg, repoName, toolName, toolVersion string) error {
+	keyPath := i.keyPath(githubOrg, repoName)
+	i.declined[keyPath+"/"+toolName] = toolVersion
+	return nil
+}
+
+func (i *InMemoryCache) GetDeclinedVersion(ctx context.Context, githubOrg, repoName, toolName string) (string, error) {
+	keyPath := i.keyPath(githubOrg, repoName)
+	toolVersion := i.declined[keyPath+"/"+toolName]
+	if toolVersion == "" {
+		return toolVersion, i.KeyNotFoundInCacheError()
+	}
+	return toolVersion, nil
+}
+
+func (i *InMemoryCache) UpdateRecreatedVersion(ctx context.Context, githubOrg, repoName, toolName, toolVersion string) error {
+	keyPath := i.keyPath(githubOrg, repoName)
+	i.recreated[keyPath+"/"+toolName] = toolVersion
+	return nil
+}
+
+func (i *InMemoryCache) GetRecreatedVersion(ctx context.Context, githubOrg, repoName, toolName string) (string, error) {
+	keyPath := i.keyPath(githubOrg, repoName)
+	toolVersion := i.recreated[keyPath+"/"+toolName]
+	if toolVersion == "" {
+		return toolVersion, i.KeyNotFoundInCacheError()
+	}
+	return toolVersion, nil
+}
+
+func (i *InMemoryCache) UpdateIgnoredCops(ctx context.Context, githubOrg, repoName, toolName string, cops []string) error {
+	keyPath := i.keyPath(githubOrg, repoName)
+	i.ignored[keyPath+"/"+toolName] = cops
+	return nil
+}
+
+func (i *InMemoryCache) GetIgnoredCops(ctx context.Context, githubOrg, repoName, toolName string) ([]string, error) {
+	keyPath := i.keyPath(githubOrg, repoName)
+	cops := i.ignored[keyPath+"/"+toolName]
+	if len(cops) == 0 {
+		return nil, i.KeyNotFoundInCacheError()
+	}
+	return cops, nil
+}
+
+func (i *InMemoryCache) UpdateTrackingIssue(ctx context.Context, githubOrg, repoName, toolName string, number int) error {
+	keyPath := i.keyPath(githubOrg, repoName)
+	i.issues[keyPath+"/"+toolName] = number
+	return nil
+}
+
+func (i *InMemoryCache) GetTrackingIssue(ctx context.Context, githubOrg, repoName, toolName string) (int, error) {
+	keyPath := i.keyPath(githubOrg, repoName)
+	number := i.issues[keyPath+"/"+toolName]
+	if number == 0 {
+		return 0, i.KeyNotFoundInCacheError()
+	}
+	return number, nil
+}
+
+func (i *InMemoryCache) UpdateGlobalToolVersion(ctx context.Context, toolName, toolVersion string) error {
+	i.globalTools[toolName] = toolVersion
+	return nil
+}
+
+func (i *InMemoryCache) GetGlobalToolVersion(ctx context.Context, toolName string) (string, error) {
+	toolVersion := i.globalTools[toolName]
+	if toolVersion == "" {
+		return toolVersion, i.KeyNotFoundInCacheError()
+	}
+	return toolVersion, nil
+}
+
+// Lists every repository in the cache as org/name, sorted
+func (i *InMemoryCache) ListRepositories(ctx context.Context) ([]string, error) {
+	repos := make([]string, 0, len(i.cache))
+	for keyPath := range i.cache {
+		repos = append(repos, keyPath)
+	}
+	sort.Strings(repos)
+	return repos, nil
+}
+
+func (i *InMemoryCache) UpdateETag(ctx context.Context, request, etag, body string) error {
+	i.etags[request] = etagEntry{etag: etag, body: body}
+	return nil
+}
+
+func (i *InMemoryCache) GetETag(ctx context.Context, request string) (string, string, error) {
+	entry, ok := i.etags[request]
+	if !ok {
+		return "", "", i.KeyNotFoundInCacheError()
+	}
+	return entry.etag, entry.body, nil
+}
+
 // Private methods
 
 func (i *InMemoryCache) keyPath(githubOrg, repoName string) string {
@@ -109,3 +109,143 @@ func TestGetToolVersion(t *testing.T) {
 		assert.Equal(t, expected, actual)
 	})
 }
+
+func TestDeclinedVersion(t *testing.T) {
+	t.Run("Errors for a repository without a declined PR", func(t *testing.T) {
+		imc := NewInMemoryCache()
+		actual, err := imc.GetDeclinedVersion(ctx, "stylelia", "newKeyRepo", "cookstyle")
+		assert.EqualError(t, err, imc.KeyNotFoundInCacheError().Error())
+		assert.Equal(t, "", actual)
+	})
+
+	t.Run("Returns the version last declined", func(t *testing.T) {
+		imc := NewInMemoryCache()
+		err := imc.UpdateDeclinedVersion(ctx, "stylelia", "snort", "cookstyle", "1.2.0")
+		assert.NoError(t, err)
+
+		actual, err := imc.GetDeclinedVersion(ctx, "stylelia", "snort", "cookstyle")
+		assert.NoError(t, err)
+		assert.Equal(t, "1.2.0", actual)
+	})
+}
+
+func TestRecreatedVersion(t *testing.T) {
+	t.Run("Errors for a repository without a recreated PR", func(t *testing.T) {
+		imc := NewInMemoryCache()
+		actual, err := imc.GetRecreatedVersion(ctx, "stylelia", "newKeyRepo", "cookstyle")
+		assert.EqualError(t, err, imc.KeyNotFoundInCacheError().Error())
+		assert.Equal(t, "", actual)
+	})
+
+	t.Run("Returns the version last recreated", func(t *testing.T) {
+		imc := NewInMemoryCache()
+		err := imc.UpdateRecreatedVersion(ctx, "stylelia", "snort", "cookstyle", "1.2.3")
+		assert.NoError(t, err)
+
+		actual, err := imc.GetRecreatedVersion(ctx, "stylelia", "snort", "cookstyle")
+		assert.NoError(t, err)
+		assert.Equal(t, "1.2.3", actual)
+	})
+}
+
+func TestIgnoredCops(t *testing.T) {
+	t.Run("Errors for a repository without ignored cops", func(t *testing.T) {
+		imc := NewInMemoryCache()
+		actual, err := imc.GetIgnoredCops(ctx, "stylelia", "newKeyRepo", "cookstyle")
+		assert.EqualError(t, err, imc.KeyNotFoundInCacheError().Error())
+		assert.Nil(t, actual)
+	})
+
+	t.Run("Returns the cops last stored", func(t *testing.T) {
+		expected := []string{"Chef/Style/CommentFormat", "Chef/Modernize/FoodcriticComments"}
+
+		imc := NewInMemoryCache()
+		err := imc.UpdateIgnoredCops(ctx, "stylelia", "snort", "cookstyle", expected)
+		assert.NoError(t, err)
+
+		actual, err := imc.GetIgnoredCops(ctx, "stylelia", "snort", "cookstyle")
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	})
+}
+
+func TestTrackingIssue(t *testing.T) {
+	t.Run("Errors for a repository without a tracking issue", func(t *testing.T) {
+		imc := NewInMemoryCache()
+		actual, err := imc.GetTrackingIssue(ctx, "stylelia", "newKeyRepo", "cookstyle")
+		assert.EqualError(t, err, imc.KeyNotFoundInCacheError().Error())
+		assert.Equal(t, 0, actual)
+	})
+
+	t.Run("Returns the issue last stored", func(t *testing.T) {
+		imc := NewInMemoryCache()
+		err := imc.UpdateTrackingIssue(ctx, "stylelia", "snort", "cookstyle", 42)
+		assert.NoError(t, err)
+
+		actual, err := imc.GetTrackingIssue(ctx, "stylelia", "snort", "cookstyle")
+		assert.NoError(t, err)
+		assert.Equal(t, 42, actual)
+	})
+}
+
+func TestUpdateGlobalToolVersion(t *testing.T) {
+	t.Run("Updates the global version of a tool", func(t *testing.T) {
+		toolName := "cookstyle"
+		expectedToolVersion := "1.2.3"
+
+		imc := NewInMemoryCache()
+		err := imc.UpdateGlobalToolVersion(ctx, toolName, "1.0.0")
+		assert.NoError(t, err)
+
+		err = imc.UpdateGlobalToolVersion(ctx, toolName, expectedToolVersion)
+		assert.NoError(t, err)
+
+		actual, err := imc.GetGlobalToolVersion(ctx, toolName)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedToolVersion, actual)
+	})
+}
+
+func TestGetGlobalToolVersion(t *testing.T) {
+	t.Run("Errors when trying to get a Tool which has never been seen", func(t *testing.T) {
+		imc := NewInMemoryCache()
+		actual, err := imc.GetGlobalToolVersion(ctx, "cookstyle")
+		assert.EqualError(t, err, imc.KeyNotFoundInCacheError().Error())
+		assert.Equal(t, "", actual)
+	})
+}
+
+func TestListRepositories(t *testing.T) {
+	t.Run("Lists every repository in the cache", func(t *testing.T) {
+		imc := NewInMemoryCache()
+		err := imc.UpdateCommitSha(ctx, "stylelia", "snort", "b64d5bae3cee6da8c305c0f46f678914cb22e483")
+		assert.NoError(t, err)
+		err = imc.UpdateToolVersion(ctx, "sous-chefs", "nginx", "cookstyle", "1.2.3")
+		assert.NoError(t, err)
+
+		actual, err := imc.ListRepositories(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"sous-chefs/nginx", "stylelia/snort"}, actual)
+	})
+}
+
+func TestETag(t *testing.T) {
+	request := "application/json https://api.github.com/repos/stylelia/snort"
+
+	t.Run("Errors for a request never made", func(t *testing.T) {
+		imc := NewInMemoryCache()
+		_, _, err := imc.GetETag(ctx, request)
+		assert.EqualError(t, err, imc.KeyNotFoundInCacheError().Error())
+	})
+
+	t.Run("Returns the ETag and body last stored", func(t *testing.T) {
+		imc := NewInMemoryCache()
+		err := imc.UpdateETag(ctx, request, `"abc"`, `{"default_branch": "main"}`)
+		assert.NoError(t, err)
+
+		etag, body, err := imc.GetETag(ctx, request)
+		assert.NoError(t, err)
+		assert.Equal(t, `"abc"`, etag)
+		assert.Equal(t, `{"default_branch": "main"}`, body)
+	})
+}
@@ -4,11 +4,32 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
-const commitShaFieldName string = "commitSha"
+const (
+	commitShaFieldName string = "commitSha"
+	repoKeyPrefix      string = "github/"
+	// Followed by the tool name, holds the version whose PR was closed without merging
+	declinedFieldPrefix string = "declined/"
+	// Followed by the tool name, holds the cops turned off in the repo, one per line
+	ignoredFieldPrefix string = "ignored/"
+	// Followed by the tool name, holds the number of the issue tracking what it couldn't correct
+	issueFieldPrefix string = "issue/"
+	// Followed by the tool name, holds the version whose PR was closed to be raised again
+	recreatedFieldPrefix string = "recreated/"
+	// Holds the latest version seen of every tool, outside of the repo keys
+	globalToolsKey string = "stylelia/tools"
+	// ETags and the bodies they were sent with, by request
+	etagKeyPrefix  string        = "stylelia/etags/"
+	etagFieldName  string        = "etag"
+	bodyFieldName  string        = "body"
+	etagExpiration time.Duration = 7 * 24 * time.Hour
+)
 
 // Creates a Redis client with methods for Updating and Getting the relevant keys
 // That matter within the Stylelia application
@@ -47,13 +68,104 @@ func (r *Redis) UpdateToolVersion(ctx context.Context, githubOrg, repoName, tool
 	return r.updateKeyField(ctx, keyPath, toolName, toolVersion)
 }
 
+func (r *Redis) GetDeclinedVersion(ctx context.Context, githubOrg, repoName, toolName string) (string, error) {
+	keyPath := r.keyPath(githubOrg, repoName)
+	return r.getKeyField(ctx, keyPath, declinedFieldPrefix+toolName)
+}
+
+func (r *Redis) UpdateDeclinedVersion(ctx context.Context, githubOrg, repoName, toolName, toolVersion string) error {
+	keyPath := r.keyPath(githubOrg, repoName)
+	return r.updateKeyField(ctx, keyPath, declinedFieldPrefix+toolName, toolVersion)
+}
+
+func (r *Redis) GetRecreatedVersion(ctx context.Context, githubOrg, repoName, toolName string) (string, error) {
+	keyPath := r.keyPath(githubOrg, repoName)
+	return r.getKeyField(ctx, keyPath, recreatedFieldPrefix+toolName)
+}
+
+func (r *Redis) UpdateRecreatedVersion(ctx context.Context, githubOrg, repoName, toolName, toolVersion string) error {
+	keyPath := r.keyPath(githubOrg, repoName)
+	return r.updateKeyField(ctx, keyPath, recreatedFieldPrefix+toolName, toolVersion)
+}
+
+func (r *Redis) GetIgnoredCops(ctx context.Context, githubOrg, repoName, toolName string) ([]string, error) {
+	keyPath := r.keyPath(githubOrg, repoName)
+	value, err := r.getKeyField(ctx, keyPath, ignoredFieldPrefix+toolName)
+	if err != nil || value == "" {
+		return nil, err
+	}
+	return strings.Split(value, "\n"), nil
+}
+
+func (r *Redis) UpdateIgnoredCops(ctx context.Context, githubOrg, repoName, toolName string, cops []string) error {
+	keyPath := r.keyPath(githubOrg, repoName)
+	return r.updateKeyField(ctx, keyPath, ignoredFieldPrefix+toolName, strings.Join(cops, "\n"))
+}
+
+func (r *Redis) GetTrackingIssue(ctx context.Context, githubOrg, repoName, toolName string) (int, error) {
+	keyPath := r.keyPath(githubOrg, repoName)
+	value, err := r.getKeyField(ctx, keyPath, issueFieldPrefix+toolName)
+	if err != nil || value == "" {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+func (r *Redis) UpdateTrackingIssue(ctx context.Context, githubOrg, repoName, toolName string, number int) error {
+	keyPath := r.keyPath(githubOrg, repoName)
+	return r.updateKeyField(ctx, keyPath, issueFieldPrefix+toolName, strconv.Itoa(number))
+}
+
+func (r *Redis) GetGlobalToolVersion(ctx context.Context, toolName string) (string, error) {
+	return r.getKeyField(ctx, globalToolsKey, toolName)
+}
+
+func (r *Redis) UpdateGlobalToolVersion(ctx context.Context, toolName, toolVersion string) error {
+	return r.updateKeyField(ctx, globalToolsKey, toolName, toolVersion)
+}
+
+// Lists every repository in the cache as org/name
+// Uses SCAN rather than KEYS so a big keyspace doesn't block Redis
+func (r *Redis) ListRepositories(ctx context.Context) ([]string, error) {
+	var repos []string
+	iter := r.client.Scan(ctx, 0, r.keyPath("*", "*"), 0).Iterator()
+	for iter.Next(ctx) {
+		repos = append(repos, strings.TrimPrefix(iter.Val(), repoKeyPrefix))
+	}
+
+	return repos, iter.Err()
+}
+
+// Returns the ETag and body of the last response to a request, both empty if there wasn't one
+func (r *Redis) GetETag(ctx context.Context, request string) (string, string, error) {
+	values, err := r.client.HMGet(ctx, etagKeyPrefix+request, etagFieldName, bodyFieldName).Result()
+	if err != nil {
+		return "", "", r.normaliseErrorCode(err)
+	}
+
+	etag, _ := values[0].(string)
+	body, _ := values[1].(string)
+	return etag, body, nil
+}
+
+// Stores the ETag and body of a response, expiring them if the request isn't made again for a week
+func (r *Redis) UpdateETag(ctx context.Context, request, etag, body string) error {
+	keyPath := etagKeyPrefix + request
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, keyPath, etagFieldName, etag, bodyFieldName, body)
+		pipe.Expire(ctx, keyPath, etagExpiration)
+		return nil
+	})
+	return r.normaliseErrorCode(err)
+}
+
 func (r *Redis) KeyNotFoundInCacheError() error {
 	return errors.New("cache: key not found")
 }
 
 // Private methods
 func (r *Redis) keyPath(githubOrg, repoName string) string {
-	return fmt.Sprintf("github/%v/%v", githubOrg, repoName)
+	return fmt.Sprintf("%v%v/%v", repoKeyPrefix, githubOrg, repoName)
 }
 
 func (r *Redis) getKeyField(ctx context.Context, keyPath, fieldName string) (string, error) {
@@ -155,3 +155,175 @@ func TestGetToolVersion(t *testing.T) {
 		assert.Equal(t, expected, actual)
 	})
 }
+
+func TestDeclinedVersion(t *testing.T) {
+	t.Run("Returns nothing for a repository without a declined PR", func(t *testing.T) {
+		r := NewRedis(redisPort, redisHost, redisPassword)
+		actual, err := r.GetDeclinedVersion(ctx, "stylelia", "newKeyRepo", "cookstyle")
+		assert.NoError(t, err)
+		assert.Equal(t, "", actual)
+	})
+
+	t.Run("Keeps the declined version apart from the tool version", func(t *testing.T) {
+		githubOrg := "stylelia"
+		repoName := "declinedRepo"
+
+		r := NewRedis(redisPort, redisHost, redisPassword)
+		defer r.deleteKey(ctx, githubOrg, repoName)
+		err := r.UpdateToolVersion(ctx, githubOrg, repoName, "cookstyle", "1.2.3")
+		assert.NoError(t, err)
+		err = r.UpdateDeclinedVersion(ctx, githubOrg, repoName, "cookstyle", "1.2.0")
+		assert.NoError(t, err)
+
+		actual, err := r.GetDeclinedVersion(ctx, githubOrg, repoName, "cookstyle")
+		assert.NoError(t, err)
+		assert.Equal(t, "1.2.0", actual)
+
+		actual, err = r.GetToolVersion(ctx, githubOrg, repoName, "cookstyle")
+		assert.NoError(t, err)
+		assert.Equal(t, "1.2.3", actual)
+	})
+}
+
+func TestIgnoredCops(t *testing.T) {
+	t.Run("Returns nothing for a repository without ignored cops", func(t *testing.T) {
+		r := NewRedis(redisPort, redisHost, redisPassword)
+		actual, err := r.GetIgnoredCops(ctx, "stylelia", "newKeyRepo", "cookstyle")
+		assert.NoError(t, err)
+		assert.Nil(t, actual)
+	})
+
+	t.Run("Returns the cops last stored", func(t *testing.T) {
+		githubOrg := "stylelia"
+		repoName := "ignoredRepo"
+		expected := []string{"Chef/Style/CommentFormat", "Chef/Modernize/FoodcriticComments"}
+
+		r := NewRedis(redisPort, redisHost, redisPassword)
+		defer r.deleteKey(ctx, githubOrg, repoName)
+		err := r.UpdateIgnoredCops(ctx, githubOrg, repoName, "cookstyle", expected)
+		assert.NoError(t, err)
+
+		actual, err := r.GetIgnoredCops(ctx, githubOrg, repoName, "cookstyle")
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	})
+}
+
+func TestRecreatedVersion(t *testing.T) {
+	t.Run("Returns nothing for a repository without a recreated PR", func(t *testing.T) {
+		r := NewRedis(redisPort, redisHost, redisPassword)
+		actual, err := r.GetRecreatedVersion(ctx, "stylelia", "newKeyRepo", "cookstyle")
+		assert.NoError(t, err)
+		assert.Equal(t, "", actual)
+	})
+
+	t.Run("Keeps the recreated version apart from the declined version", func(t *testing.T) {
+		githubOrg := "stylelia"
+		repoName := "recreatedRepo"
+
+		r := NewRedis(redisPort, redisHost, redisPassword)
+		defer r.deleteKey(ctx, githubOrg, repoName)
+		err := r.UpdateDeclinedVersion(ctx, githubOrg, repoName, "cookstyle", "1.2.0")
+		assert.NoError(t, err)
+		err = r.UpdateRecreatedVersion(ctx, githubOrg, repoName, "cookstyle", "1.2.3")
+		assert.NoError(t, err)
+
+		actual, err := r.GetRecreatedVersion(ctx, githubOrg, repoName, "cookstyle")
+		assert.NoError(t, err)
+		assert.Equal(t, "1.2.3", actual)
+
+		actual, err = r.GetDeclinedVersion(ctx, githubOrg, repoName, "cookstyle")
+		assert.NoError(t, err)
+		assert.Equal(t, "1.2.0", actual)
+	})
+}
+
+func TestTrackingIssue(t *testing.T) {
+	t.Run("Returns nothing for a repository without a tracking issue", func(t *testing.T) {
+		r := NewRedis(redisPort, redisHost, redisPassword)
+		actual, err := r.GetTrackingIssue(ctx, "stylelia", "newKeyRepo", "cookstyle")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, actual)
+	})
+
+	t.Run("Returns the issue last stored", func(t *testing.T) {
+		githubOrg := "stylelia"
+		repoName := "issueRepo"
+
+		r := NewRedis(redisPort, redisHost, redisPassword)
+		defer r.deleteKey(ctx, githubOrg, repoName)
+		err := r.UpdateTrackingIssue(ctx, githubOrg, repoName, "cookstyle", 42)
+		assert.NoError(t, err)
+
+		actual, err := r.GetTrackingIssue(ctx, githubOrg, repoName, "cookstyle")
+		assert.NoError(t, err)
+		assert.Equal(t, 42, actual)
+	})
+}
+
+func TestUpdateGlobalToolVersion(t *testing.T) {
+	t.Run("Updates the global version of a tool", func(t *testing.T) {
+		toolName := "cookstyle"
+		expectedToolVersion := "1.2.3"
+
+		r := NewRedis(redisPort, redisHost, redisPassword)
+		defer r.client.Del(ctx, globalToolsKey)
+		err := r.UpdateGlobalToolVersion(ctx, toolName, "1.0.0")
+		assert.NoError(t, err)
+
+		err = r.UpdateGlobalToolVersion(ctx, toolName, expectedToolVersion)
+		assert.NoError(t, err)
+
+		actual, err := r.GetGlobalToolVersion(ctx, toolName)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedToolVersion, actual)
+	})
+}
+
+func TestListRepositories(t *testing.T) {
+	t.Run("Lists every repository in the cache and nothing else", func(t *testing.T) {
+		r := NewRedis(redisPort, redisHost, redisPassword)
+		defer r.deleteKey(ctx, "stylelia", "listRepo")
+		defer r.deleteKey(ctx, "sous-chefs", "listRepo")
+		defer r.client.Del(ctx, globalToolsKey)
+
+		err := r.UpdateCommitSha(ctx, "stylelia", "listRepo", "b64d5bae3cee6da8c305c0f46f678914cb22e483")
+		assert.NoError(t, err)
+		err = r.UpdateToolVersion(ctx, "sous-chefs", "listRepo", "cookstyle", "1.2.3")
+		assert.NoError(t, err)
+		err = r.UpdateGlobalToolVersion(ctx, "cookstyle", "1.2.3")
+		assert.NoError(t, err)
+
+		actual, err := r.ListRepositories(ctx)
+		assert.NoError(t, err)
+		assert.Contains(t, actual, "stylelia/listRepo")
+		assert.Contains(t, actual, "sous-chefs/listRepo")
+		assert.NotContains(t, actual, globalToolsKey)
+	})
+}
+
+func TestETag(t *testing.T) {
+	request := "application/json https://api.github.com/repos/stylelia/etagRepo"
+
+	t.Run("Returns nothing for a request never made", func(t *testing.T) {
+		r := NewRedis(redisPort, redisHost, redisPassword)
+
+		etag, body, err := r.GetETag(ctx, request)
+		assert.NoError(t, err)
+		assert.Empty(t, etag)
+		assert.Empty(t, body)
+	})
+
+	t.Run("Returns the ETag and body last stored", func(t *testing.T) {
+		r := NewRedis(redisPort, redisHost, redisPassword)
+		defer r.client.Del(ctx, etagKeyPrefix+request)
+
+		err := r.UpdateETag(ctx, request, `"abc"`, `{"default_branch": "main"}`)
+		assert.NoError(t, err)
+
+		etag, body, err := r.GetETag(ctx, request)
+		assert.NoError(t, err)
+		assert.Equal(t, `"abc"`, etag)
+		assert.Equal(t, `{"default_branch": "main"}`, body)
+	})
+}